@@ -2,44 +2,268 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/HasinduLanka/InspectGo/pkg/auth"
 	"github.com/HasinduLanka/InspectGo/pkg/inspector"
+	"github.com/HasinduLanka/InspectGo/pkg/ratelimit"
 )
 
 type inspectEndpointRequest struct {
 	URL string `json:"url"`
+
+	// HTML document to inspect instead of fetching URL. URL is then the base URL the document would be served from
+	HTML string `json:"html"`
+
+	// Time budget for the whole request in milliseconds. Defaults to, and is capped at MaxAPIRequestDuration
+	TimeBudgetMS int `json:"time_budget_ms"`
+
+	// Continuation token from a previous final report. When given, the inspection resumes from the token and URL is ignored
+	Continuation string `json:"continuation"`
+
+	// Analyse links. Defaults to true
+	LinkCheck *bool `json:"link_check"`
+
+	// Timeout for fetching the web page and for each link check in milliseconds. Defaults to the time budget
+	TimeoutMS int `json:"timeout_ms"`
+
+	// Maximum number of links to analyse. Zero means no limit
+	MaxLinks int `json:"max_links"`
+
+	// Only check a stratified random sample of this many links, and estimate broken link rates from it. Zero checks all links
+	SampleSize int `json:"sample_size"`
+
+	// Only analyse links of these types. Defaults to all of inspector.AnalysableLinkTypes
+	LinkTypes []string `json:"link_types"`
+
+	// Order of link checks. One of inspector.LinkOrders. Defaults to document order
+	LinkOrder string `json:"link_order"`
+
+	// Headers sent with link checks. One of "browser" (default), "bot" or "none"
+	HeaderProfile string `json:"header_profile"`
+
+	// Follow redirects. Defaults to true
+	FollowRedirects *bool `json:"follow_redirects"`
+
+	// Also describe the TLS certificates of the hosts of checked links
+	LinkTLS bool `json:"link_tls"`
+
+	// Credentials for pages behind a login or basic auth, by cookie and header name. Never logged
+	Cookies   map[string]string    `json:"cookies"`
+	Headers   map[string]string    `json:"headers"`
+	BasicAuth *inspector.BasicAuth `json:"basic_auth"`
+
+	// Which link checks get the credentials. "page" (default) or "same-host"
+	CredentialPolicy string `json:"credential_policy"`
+
+	// Log in with the login form of a page before the inspection. Credentials in its fields are never logged
+	Login *inspector.LoginFlow `json:"login"`
+
+	// Certificate authorities trusted besides the system ones, and a client certificate and key for mutual TLS, in PEM format.
+	// The private key is never logged
+	CABundle          string `json:"ca_bundle"`
+	ClientCertificate string `json:"client_certificate"`
+	ClientKey         string `json:"client_key"`
+
+	// Skip verifying TLS certificates. The report is marked with insecure_tls
+	InsecureSkipVerify bool `json:"insecure_skip_verify"`
+}
+
+// options converts the request into inspector options, and validates them
+func (reqBody *inspectEndpointRequest) options(req *http.Request, finalFlushAt time.Time) (*inspector.Options, *inspector.InspectError) {
+
+	if reqBody.TimeBudgetMS < 0 || reqBody.TimeoutMS < 0 {
+		return nil, inspector.NewInspectError(inspector.ErrorCodeInvalidRequest, "Time budget and timeout can not be negative", false, nil)
+	}
+
+	opts := &inspector.Options{
+		Timeout:          time.Duration(reqBody.TimeoutMS) * time.Millisecond,
+		MaxLinks:         reqBody.MaxLinks,
+		SampleSize:       reqBody.SampleSize,
+		LinkTypes:        reqBody.LinkTypes,
+		LinkOrder:        reqBody.LinkOrder,
+		HeaderProfile:    reqBody.HeaderProfile,
+		DisableRedirects: reqBody.FollowRedirects != nil && !*reqBody.FollowRedirects,
+		CheckLinkTLS:     reqBody.LinkTLS,
+		AllowedNetworks:  AllowedNetworks,
+		BasicAuth:        reqBody.BasicAuth,
+		CredentialPolicy: reqBody.CredentialPolicy,
+		Login:            reqBody.Login,
+
+		CABundle:           []byte(reqBody.CABundle),
+		ClientCertificate:  []byte(reqBody.ClientCertificate),
+		ClientKey:          []byte(reqBody.ClientKey),
+		InsecureSkipVerify: reqBody.InsecureSkipVerify,
+	}
+
+	for cookieName, cookieValue := range reqBody.Cookies {
+		opts.Cookies = append(opts.Cookies, &http.Cookie{Name: cookieName, Value: cookieValue})
+	}
+
+	if len(reqBody.Headers) > 0 {
+		opts.Headers = http.Header{}
+		for headerName, headerValue := range reqBody.Headers {
+			opts.Headers.Set(headerName, headerValue)
+		}
+	}
+
+	// Inspections share link analysers in turns, weighted by the priority of their API key
+	if key := auth.KeyFromContext(req.Context()); key != nil {
+		opts.Priority = key.Priority
+		opts.Client = key.Name
+	}
+
+	// Link analysis sends requests to other websites, which needs the crawl scope.
+	// Keys without it get no link analysis by default
+	canCrawl := auth.HasScope(req.Context(), auth.ScopeCrawl)
+	if reqBody.LinkCheck != nil && *reqBody.LinkCheck && !canCrawl {
+		return nil, inspector.NewInspectError(inspector.ErrorCodeForbidden, "Link checks need an API key with the crawl scope", false, nil)
+	}
+
+	if (reqBody.LinkCheck == nil && canCrawl) || (reqBody.LinkCheck != nil && *reqBody.LinkCheck) {
+		opts.LinkAnalyticsDeadline = &finalFlushAt
+
+	} else if opts.Timeout == 0 || opts.Timeout > time.Until(finalFlushAt) {
+		// Without link analytics, the budget only bounds the page fetch
+		opts.Timeout = time.Until(finalFlushAt)
+	}
+
+	if validationErr := opts.Validate(); validationErr != nil {
+		return nil, validationErr
+	}
+
+	return opts, nil
 }
 
 func InspectEndpoint(wr http.ResponseWriter, req *http.Request) {
 
-	bestBefore := time.Now().Add(MaxAPIRequestDuration)
+	requestStart := time.Now()
+
+	if req.Method != http.MethodPost {
+		wr.Header().Set("Allow", http.MethodPost)
+		inspector.WriteError(wr, http.StatusMethodNotAllowed, inspector.NewInspectError(inspector.ErrorCodeMethodNotAllowed, "Only POST requests are accepted", false, nil))
+		return
+	}
+
+	// Each client gets a limited number of inspections, as each one can send hundreds of requests.
+	// This comes before the key check, so guessing API keys is rate limited too
+	releaseRateLimit, admitted := InspectRateLimiter.Admit(wr, req)
+	if !admitted {
+		logInspect(req, "rate limited : "+InspectRateLimiter.ClientIP(req))
+		return
+	}
+	defer releaseRateLimit()
+
+	// Only known API keys may inspect, if API keys are configured
+	req, isAuthorized := APIKeys.Require(wr, req, auth.ScopeInspect)
+	if !isAuthorized {
+		logInspect(req, "unauthorized")
+		return
+	}
 
 	var reqBody inspectEndpointRequest
 
 	// Decode the request body into `inspectEndpointRequest`
-	decoder := json.NewDecoder(req.Body)
+	decoder := json.NewDecoder(http.MaxBytesReader(wr, req.Body, MaxRequestBodySize))
+	decoder.DisallowUnknownFields()
 	decodeErr := decoder.Decode(&reqBody)
 
 	// If there was an error decoding the request body, return an error
 	if decodeErr != nil {
-		log.Println("endpoint /inspect : request parse error : " + decodeErr.Error())
-		http.Error(wr, decodeErr.Error(), http.StatusBadRequest)
+		logInspect(req, "request parse error : "+decodeErr.Error())
+		inspector.WriteError(wr, http.StatusBadRequest, inspector.NewInspectError(inspector.ErrorCodeInvalidRequest, "Request body is not valid JSON, has unknown fields or is too large", false, decodeErr))
 		return
 	}
 
-	inspectResp := inspector.InspectURL(reqBody.URL, &bestBefore)
-	// If there was an error inspecting the URL, it will be returned in the response
+	budget := MaxAPIRequestDuration
+	if reqBody.TimeBudgetMS > 0 && time.Duration(reqBody.TimeBudgetMS)*time.Millisecond < budget {
+		budget = time.Duration(reqBody.TimeBudgetMS) * time.Millisecond
+	}
+
+	// Link analysis stops at finalFlushAt, leaving some of the budget to write the final report
+	finalFlushAt := requestStart.Add(budget - minDuration(FinalReportReserve, budget/4))
+
+	opts, optsErr := reqBody.options(req, finalFlushAt)
+	if optsErr != nil {
+		logInspect(req, "invalid options : "+optsErr.Message)
+		if optsErr.Code == inspector.ErrorCodeForbidden {
+			inspector.WriteError(wr, http.StatusForbidden, optsErr)
+		} else {
+			inspector.WriteError(wr, http.StatusBadRequest, optsErr)
+		}
+		return
+	}
+
+	var inspectResp *inspector.InspectReport
+
+	if len(reqBody.Continuation) > 0 && len(reqBody.HTML) > 0 {
+		logInspect(req, "both continuation and html given")
+		inspector.WriteError(wr, http.StatusBadRequest, inspector.NewInspectError(inspector.ErrorCodeInvalidRequest, "A continuation token can not be combined with an HTML document", false, nil))
+		return
+	}
+
+	if len(reqBody.Continuation) > 0 {
+		if len(ContinuationSecret) == 0 {
+			logInspect(req, "continuation tokens are disabled")
+			inspector.WriteError(wr, http.StatusBadRequest, inspector.NewInspectError(inspector.ErrorCodeInvalidContinuation, "Continuation tokens are disabled on this server", false, nil))
+			return
+		}
+
+		var resumeErr error
+		inspectResp, resumeErr = inspector.ResumeInspection(reqBody.Continuation, ContinuationSecret, opts)
+
+		if errors.Is(resumeErr, inspector.ErrContinuationWithoutDeadline) {
+			logInspect(req, "continuation without link checks")
+			inspector.WriteError(wr, http.StatusBadRequest, inspector.NewInspectError(inspector.ErrorCodeInvalidRequest, "Resuming an inspection needs link checks", false, resumeErr))
+			return
+		}
+
+		if errors.Is(resumeErr, inspector.ErrContinuationOtherClient) {
+			logInspect(req, "continuation of another client")
+			inspector.WriteError(wr, http.StatusForbidden, inspector.NewInspectError(inspector.ErrorCodeForbidden, "The continuation token belongs to another API key", false, nil))
+			return
+		}
+
+		if resumeErr != nil {
+			logInspect(req, "continuation error : "+resumeErr.Error())
+			inspector.WriteError(wr, http.StatusBadRequest, inspector.NewInspectError(inspector.ErrorCodeInvalidContinuation, "Continuation token is invalid or expired", false, resumeErr))
+			return
+		}
+
+	} else {
+		// Reject malformed URLs and unsupported schemes before fetching anything
+		normalizedURL, urlErr := inspector.NormalizeURL(reqBody.URL)
+		if urlErr != nil {
+			logInspect(req, "invalid URL : "+urlErr.Message)
+			inspector.WriteError(wr, http.StatusBadRequest, urlErr)
+			return
+		}
+
+		if len(reqBody.HTML) > 0 {
+			logInspect(req, "inspecting HTML document with base URL "+normalizedURL)
+			inspectResp = inspector.InspectHTML(strings.NewReader(reqBody.HTML), normalizedURL, opts)
+		} else {
+			logInspect(req, "inspecting "+normalizedURL)
+			inspectResp = inspector.InspectURLWithOptions(normalizedURL, opts)
+		}
+		// If there was an error inspecting the URL, it will be returned in the response
+	}
+
+	wr.Header().Set("Content-Type", "application/json")
 
 	// Return the response in chunks (response streaming)
 	flusher, flusherAvailable := wr.(http.Flusher)
 
 	if !flusherAvailable {
-		log.Println("endpoint /inspect : response streaming unavailable in this platform")
+		logInspect(req, "response streaming unavailable in this platform")
 	}
 
 	// check request headers for "inspector-response-streamable"
@@ -50,10 +274,11 @@ func InspectEndpoint(wr http.ResponseWriter, req *http.Request) {
 	// Make sure not to write two responses at once
 	respondLock := sync.Mutex{}
 
-	respondReport := func() {
+	respondReport := func(isFinal bool) {
 
 		if flusherAvailable {
 			respondLock.Lock()
+			defer respondLock.Unlock()
 		}
 
 		inspectResp.CountLinks()
@@ -61,8 +286,8 @@ func InspectEndpoint(wr http.ResponseWriter, req *http.Request) {
 
 		// If there was an error encoding the response body, return an error
 		if respEncodeErr != nil {
-			log.Println("endpoint /inspect : response encode error : " + respEncodeErr.Error())
-			http.Error(wr, respEncodeErr.Error(), http.StatusInternalServerError)
+			logInspect(req, "response encode error : "+respEncodeErr.Error())
+			inspector.WriteError(wr, http.StatusInternalServerError, inspector.NewInspectError(inspector.ErrorCodeInternal, "The report could not be encoded", true, respEncodeErr))
 			return
 		}
 
@@ -71,10 +296,10 @@ func InspectEndpoint(wr http.ResponseWriter, req *http.Request) {
 			flusher.Flush()
 		}
 
-		if flusherAvailable {
-			// Keep enough time for the response to be read by client
-			time.Sleep(10 * time.Second)
-			respondLock.Unlock()
+		if flusherAvailable && !isFinal {
+			// Keep enough time for the response to be read by client,
+			// but never hold back the final report beyond the budget
+			time.Sleep(minDuration(StreamChunkPause, time.Until(finalFlushAt)))
 		}
 	}
 
@@ -82,52 +307,103 @@ func InspectEndpoint(wr http.ResponseWriter, req *http.Request) {
 
 	if flusherAvailable {
 		// Return the initial report. This won't contain link analysis information
-		respondReport()
-		log.Println("endpoint /inspect : initial report returned")
-
-		time.Sleep(2 * time.Second)
+		respondReport(false)
+		logInspect(req, "initial report returned")
 
 		// Ticker to respond every 20 seconds
 		ticker := time.NewTicker(20 * time.Second)
 		defer ticker.Stop()
 
-		endChannel := make(chan bool)
+		endChannel = make(chan bool)
 
 		go func() {
 			for {
 				select {
 				case <-ticker.C:
-					respondReport()
-					log.Println("endpoint /inspect : ticking report returned")
+					respondReport(false)
+					logInspect(req, "ticking report returned")
 
 				case <-endChannel:
-					log.Println("endpoint /inspect : request streaming done")
+					logInspect(req, "request streaming done")
 					return
 				}
 			}
 		}()
 	}
 
-	// Wait for the link analysis to finish
+	// Wait for the link analysis to finish, or for the budget to run out
 	if inspectResp.LinkAnalyticWG != nil {
-		inspectResp.LinkAnalyticWG.Wait()
+		linkAnalysisDone := make(chan struct{})
+		go func() {
+			inspectResp.LinkAnalyticWG.Wait()
+			close(linkAnalysisDone)
+		}()
+
+		flushTimer := time.NewTimer(time.Until(finalFlushAt))
+		defer flushTimer.Stop()
+
+		select {
+		case <-linkAnalysisDone:
+		case <-flushTimer.C:
+			logInspect(req, "time budget reached before link analysis finished")
+		}
 	}
 
 	if endChannel != nil {
 		endChannel <- true
 	}
 
+	// Links that are still being analysed are marked as unfinished
+	inspectResp.FinishLinkAnalysis()
+
+	// Let the client resume the unfinished links in another request, which may hit another instance
+	inspectResp.CountLinks()
+	if inspectResp.UnfinishedLinkCount > 0 && len(ContinuationSecret) > 0 {
+		token, tokenErr := inspectResp.ContinuationToken(ContinuationSecret)
+		if tokenErr != nil {
+			logInspect(req, "continuation token error : "+tokenErr.Error())
+		} else {
+			inspectResp.Continuation = token
+		}
+	}
+
 	// Return the final report
-	respondReport()
-	log.Println("endpoint /inspect : final report returned")
+	respondReport(true)
+	logInspect(req, "final report returned")
+}
 
-	if inspectResp.RequestContextCancel != nil {
-		inspectResp.RequestContextCancel()
-	}
+// logInspect logs a message of the inspect endpoint, attributed to the API key of the request
+func logInspect(req *http.Request, message string) {
+	log.Println("endpoint /inspect : " + auth.ClientName(req.Context()) + " : " + message)
 }
 
 var MaxAPIRequestDuration = getMaxAPIRequestDuration()
 
+// Time kept aside at the end of the budget to write the final report
+var FinalReportReserve = getFinalReportReserve()
+
+// Maximum size of a request body in bytes, which mostly limits the size of HTML documents to inspect
+var MaxRequestBodySize int64 = 5 << 20
+
+// Secret used to sign continuation tokens. It must be the same on every instance.
+// Continuation tokens are disabled if INSPECTOR_CONTINUATION_SECRET is not set
+var ContinuationSecret = []byte(os.Getenv(`INSPECTOR_CONTINUATION_SECRET`))
+
+// Private networks that may be inspected, from the comma separated INSPECTOR_ALLOWED_NETWORKS environment variable.
+// Everything in inspector.BlockedNetworks is blocked by default
+var AllowedNetworks = getAllowedNetworks()
+
+// API keys from INSPECTOR_API_KEYS_FILE or INSPECTOR_API_KEYS. Authentication is disabled if neither is set
+var APIKeys = getAPIKeys()
+
+// Rate limit of the inspect endpoint, per client. Configured with the environment variables
+// INSPECTOR_RATE_LIMIT_PER_MINUTE (default 30), INSPECTOR_RATE_LIMIT_BURST (default 10),
+// INSPECTOR_MAX_CONCURRENT_INSPECTIONS (default 2) and INSPECTOR_TRUST_FORWARDED_FOR (default true on Vercel)
+var InspectRateLimiter = getInspectRateLimiter()
+
+// Pause after streaming each intermediate report, so the client can read it separately
+var StreamChunkPause = 10 * time.Second
+
 func getMaxAPIRequestDuration() time.Duration {
 	_, isVercel := os.LookupEnv(`VERCEL`)
 	if isVercel {
@@ -136,3 +412,79 @@ func getMaxAPIRequestDuration() time.Duration {
 		return time.Minute * 3
 	}
 }
+
+func getFinalReportReserve() time.Duration {
+	_, isVercel := os.LookupEnv(`VERCEL`)
+	if isVercel {
+		return time.Second
+	} else {
+		return time.Second * 5
+	}
+}
+
+func minDuration(a time.Duration, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func getAllowedNetworks() []*net.IPNet {
+	allowedNetworks, parseErr := inspector.ParseNetworks(os.Getenv(`INSPECTOR_ALLOWED_NETWORKS`))
+	if parseErr != nil {
+		log.Println("INSPECTOR_ALLOWED_NETWORKS is invalid, private networks stay blocked : " + parseErr.Error())
+		return nil
+	}
+	return allowedNetworks
+}
+
+func getAPIKeys() *auth.KeyStore {
+	keyStore, keyStoreErr := auth.KeyStoreFromEnv()
+	if keyStoreErr != nil {
+		// Failing open would expose the server, so refuse everything instead
+		log.Println("API keys could not be loaded, all requests will be refused : " + keyStoreErr.Error())
+		keyStore, _ = auth.NewKeyStore(nil)
+	}
+	return keyStore
+}
+
+func getInspectRateLimiter() *ratelimit.Limiter {
+	limiter := ratelimit.New(
+		getEnvFloat(`INSPECTOR_RATE_LIMIT_PER_MINUTE`, 30)/60,
+		int(getEnvFloat(`INSPECTOR_RATE_LIMIT_BURST`, 10)),
+		int(getEnvFloat(`INSPECTOR_MAX_CONCURRENT_INSPECTIONS`, 2)),
+	)
+
+	// Vercel always sets X-Forwarded-For to the real client IP
+	_, isVercel := os.LookupEnv(`VERCEL`)
+	limiter.TrustForwardedFor = isVercel || os.Getenv(`INSPECTOR_TRUST_FORWARDED_FOR`) == "true"
+	limiter.TrustedProxies = int(getEnvFloat(`INSPECTOR_TRUSTED_PROXIES`, 1))
+
+	// Clients with a valid API key are limited by their key, wherever they connect from.
+	// The limiter runs before the key check, so it authenticates the key itself
+	limiter.KeyFunc = func(req *http.Request) string {
+		if APIKeys != nil {
+			if key, isKnown := APIKeys.Authenticate(req); isKnown {
+				return "key:" + key.Name
+			}
+		}
+		return limiter.ClientIP(req)
+	}
+
+	return limiter
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	envValue, hasEnvValue := os.LookupEnv(key)
+	if !hasEnvValue {
+		return defaultValue
+	}
+
+	parsedValue, parseErr := strconv.ParseFloat(envValue, 64)
+	if parseErr != nil || parsedValue < 0 {
+		log.Println(key + " is not a valid number, using the default value")
+		return defaultValue
+	}
+
+	return parsedValue
+}
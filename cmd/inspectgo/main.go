@@ -0,0 +1,526 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/HasinduLanka/InspectGo/pkg/inspector"
+)
+
+// This is a command line tool to inspect web pages, for use in CI pipelines.
+// It exits with a non zero code when an inspected page fails one of the checks given as flags.
+//
+//	go run ./cmd/inspectgo -require-title -fail-on-broken-internal https://preview.example.com
+//
+// With -html, it inspects an HTML file (or stdin for "-") as if it was served from the single URL given
+//
+//	go run ./cmd/inspectgo -html build/index.html https://example.com
+
+// Exit codes
+const (
+	exitOK          = 0
+	exitCheckFailed = 1
+	exitUsage       = 2
+)
+
+type cliConfig struct {
+	opts       inspector.Options
+	jsonOutput bool
+	linkCheck  bool
+	timeout    time.Duration
+
+	// HTML file to inspect instead of fetching the URL, or "-" for stdin
+	htmlPath string
+
+	// Checks
+	requireTitle         bool
+	failOnBrokenInternal bool
+	failOnPageError      bool
+	failOnMixedContent   bool
+	maxInaccessibleLinks int
+	maxUnfinishedLinks   int
+}
+
+// stringList is a flag that can be repeated
+type stringList []string
+
+func (list *stringList) String() string {
+	return strings.Join(*list, ", ")
+}
+
+func (list *stringList) Set(value string) error {
+	*list = append(*list, value)
+	return nil
+}
+
+// Result of inspecting one URL
+type cliResult struct {
+	Report   *inspector.InspectReport `json:"report"`
+	Failures []string                 `json:"failures"`
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	config, urls, configErr := parseFlags(args, stderr)
+	if errors.Is(configErr, flag.ErrHelp) {
+		return exitOK
+	}
+	if configErr != nil {
+		fmt.Fprintln(stderr, configErr)
+		return exitUsage
+	}
+
+	if len(config.htmlPath) > 0 {
+		document, readErr := readDocument(config.htmlPath, stdin)
+		if readErr != nil {
+			fmt.Fprintln(stderr, readErr)
+			return exitUsage
+		}
+
+		result := inspectHTML(document, urls[0], config)
+		return printResults(stdout, []*cliResult{result}, config)
+	}
+
+	results := make([]*cliResult, len(urls))
+	wg := sync.WaitGroup{}
+
+	for i, inputURL := range urls {
+		wg.Add(1)
+		go func(i int, inputURL string) {
+			defer wg.Done()
+			results[i] = inspect(inputURL, config)
+		}(i, inputURL)
+	}
+	wg.Wait()
+
+	return printResults(stdout, results, config)
+}
+
+// printResults prints the results, and returns the exit code
+func printResults(stdout io.Writer, results []*cliResult, config *cliConfig) int {
+	if config.jsonOutput {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(results)
+	} else {
+		for _, result := range results {
+			printResult(stdout, result)
+		}
+	}
+
+	for _, result := range results {
+		if len(result.Failures) > 0 {
+			return exitCheckFailed
+		}
+	}
+
+	return exitOK
+}
+
+func parseFlags(args []string, stderr io.Writer) (*cliConfig, []string, error) {
+	config := &cliConfig{}
+
+	flags := flag.NewFlagSet("inspectgo", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: inspectgo [flags] url [url...]")
+		fmt.Fprintln(stderr, "       inspectgo [flags] -html file base-url")
+		fmt.Fprintln(stderr, "Inspects web pages and exits with code 1 if any of them fails a check, or 2 on invalid usage.")
+		flags.PrintDefaults()
+	}
+
+	var linkTypes string
+	var allowedNetworks string
+	var headers, cookies stringList
+	var basicAuth string
+	var loginURL string
+	var loginFields stringList
+	var caBundlePath, clientCertPath, clientKeyPath string
+
+	flags.BoolVar(&config.jsonOutput, "json", false, "print the reports as JSON instead of a table")
+	flags.StringVar(&config.htmlPath, "html", "", "inspect this HTML file, or - for stdin, as if it was served from the URL")
+	flags.BoolVar(&config.linkCheck, "link-check", true, "analyse links")
+	flags.DurationVar(&config.timeout, "timeout", time.Minute, "time limit for each inspection, including link analysis")
+	flags.DurationVar(&config.opts.Timeout, "request-timeout", 0, "timeout for fetching the page and for each link check (default no limit other than -timeout)")
+	flags.IntVar(&config.opts.MaxLinks, "max-links", 0, "maximum number of links to analyse (default no limit)")
+	flags.IntVar(&config.opts.SampleSize, "sample-size", 0, "only check a stratified random sample of this many links (default all links)")
+	flags.StringVar(&linkTypes, "link-types", "", "comma separated link types to analyse: "+strings.Join(inspector.AnalysableLinkTypes, ", ")+" (default all)")
+	flags.StringVar(&config.opts.LinkOrder, "link-order", inspector.LinkOrderDocument, "order of link checks: "+strings.Join(inspector.LinkOrders, ", "))
+	flags.StringVar(&config.opts.HeaderProfile, "header-profile", inspector.HeaderProfileBrowser, "headers sent with link checks: browser, bot, none")
+	flags.BoolVar(&config.opts.DisableRedirects, "no-redirects", false, "report redirects instead of following them")
+	flags.BoolVar(&config.opts.CheckLinkTLS, "link-tls", false, "also describe the TLS certificates of the hosts of checked links")
+	flags.Var(&headers, "header", "header sent with the page request as \"Name: value\", can be repeated")
+	flags.Var(&cookies, "cookie", "cookie sent with the page request as name=value, can be repeated")
+	flags.StringVar(&basicAuth, "basic-auth", "", "basic auth credentials sent with the page request as username:password")
+	flags.StringVar(&loginURL, "login-url", "", "log in with the form with a password field on this page before the inspection")
+	flags.Var(&loginFields, "login-field", "value of a login form field as name=value, such as username=me, can be repeated")
+	flags.StringVar(&config.opts.CredentialPolicy, "credential-policy", inspector.CredentialPolicyPage, "link checks that get the credentials: "+strings.Join(inspector.CredentialPolicies, ", "))
+	flags.StringVar(&caBundlePath, "ca-bundle", "", "PEM file of certificate authorities trusted besides the system ones, for staging websites with a private CA")
+	flags.StringVar(&clientCertPath, "client-cert", "", "PEM file of the client certificate for websites that require mutual TLS")
+	flags.StringVar(&clientKeyPath, "client-key", "", "PEM file of the private key of -client-cert")
+	flags.BoolVar(&config.opts.InsecureSkipVerify, "insecure", false, "skip verifying TLS certificates. Reports are marked as insecure")
+	flags.StringVar(&allowedNetworks, "allow-networks", "", "comma separated private networks that may be inspected, such as 127.0.0.0/8 for local previews")
+
+	flags.BoolVar(&config.requireTitle, "require-title", false, "fail if a page has no title")
+	flags.BoolVar(&config.failOnBrokenInternal, "fail-on-broken-internal", false, "fail if a page has any inaccessible internal link")
+	flags.BoolVar(&config.failOnMixedContent, "fail-on-mixed-content", false, "fail if an HTTPS page loads scripts, iframes, stylesheets or other active content over plain HTTP")
+	flags.BoolVar(&config.failOnPageError, "fail-on-page-error", true, "fail if a page can not be fetched, or returns an error status or a bot challenge")
+	flags.IntVar(&config.maxInaccessibleLinks, "max-inaccessible", -1, "fail if a page has more inaccessible links than this (default no limit)")
+	flags.IntVar(&config.maxUnfinishedLinks, "max-unfinished", -1, "fail if more links than this could not be checked within -timeout (default no limit)")
+
+	if parseErr := flags.Parse(args); parseErr != nil {
+		return nil, nil, parseErr
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return nil, nil, fmt.Errorf("no URL to inspect")
+	}
+
+	if len(config.htmlPath) > 0 && flags.NArg() != 1 {
+		return nil, nil, fmt.Errorf("-html needs exactly one base URL")
+	}
+
+	if len(linkTypes) > 0 {
+		config.opts.LinkTypes = strings.Split(linkTypes, ",")
+	}
+
+	if credentialsErr := config.parseCredentials(headers, cookies, basicAuth, loginURL, loginFields); credentialsErr != nil {
+		return nil, nil, credentialsErr
+	}
+
+	if tlsErr := config.readTLSFiles(caBundlePath, clientCertPath, clientKeyPath); tlsErr != nil {
+		return nil, nil, tlsErr
+	}
+
+	parsedNetworks, networksErr := inspector.ParseNetworks(allowedNetworks)
+	if networksErr != nil {
+		return nil, nil, fmt.Errorf("invalid -allow-networks : %w", networksErr)
+	}
+	config.opts.AllowedNetworks = parsedNetworks
+
+	if validationErr := config.opts.Validate(); validationErr != nil {
+		return nil, nil, fmt.Errorf("invalid flags : %s", validationErr.Message)
+	}
+
+	for _, inputURL := range flags.Args() {
+		if _, urlErr := inspector.NormalizeURL(inputURL); urlErr != nil {
+			return nil, nil, fmt.Errorf("invalid URL %q : %s", inputURL, urlErr.Message)
+		}
+	}
+
+	return config, flags.Args(), nil
+}
+
+// readTLSFiles reads the CA bundle and client certificate files given as flags into the options
+func (config *cliConfig) readTLSFiles(caBundlePath string, clientCertPath string, clientKeyPath string) error {
+	files := []struct {
+		flagName string
+		path     string
+		contents *[]byte
+	}{
+		{"-ca-bundle", caBundlePath, &config.opts.CABundle},
+		{"-client-cert", clientCertPath, &config.opts.ClientCertificate},
+		{"-client-key", clientKeyPath, &config.opts.ClientKey},
+	}
+
+	for _, file := range files {
+		if len(file.path) == 0 {
+			continue
+		}
+
+		contents, readErr := os.ReadFile(file.path)
+		if readErr != nil {
+			return fmt.Errorf("invalid %s : %w", file.flagName, readErr)
+		}
+		*file.contents = contents
+	}
+
+	return nil
+}
+
+// parseCredentials adds the credentials given as flags to the options
+func (config *cliConfig) parseCredentials(headers []string, cookies []string, basicAuth string, loginURL string, loginFields []string) error {
+	for _, header := range headers {
+		headerParts := strings.SplitN(header, ":", 2)
+		if len(headerParts) != 2 {
+			return fmt.Errorf("invalid -header %q, expected \"Name: value\"", header)
+		}
+
+		if config.opts.Headers == nil {
+			config.opts.Headers = http.Header{}
+		}
+		config.opts.Headers.Add(strings.TrimSpace(headerParts[0]), strings.TrimSpace(headerParts[1]))
+	}
+
+	for _, cookie := range cookies {
+		cookieParts := strings.SplitN(cookie, "=", 2)
+		if len(cookieParts) != 2 {
+			return fmt.Errorf("invalid -cookie %q, expected name=value", cookie)
+		}
+
+		config.opts.Cookies = append(config.opts.Cookies, &http.Cookie{Name: cookieParts[0], Value: cookieParts[1]})
+	}
+
+	if len(basicAuth) > 0 {
+		basicAuthParts := strings.SplitN(basicAuth, ":", 2)
+		if len(basicAuthParts) != 2 {
+			return fmt.Errorf("invalid -basic-auth, expected username:password")
+		}
+
+		config.opts.BasicAuth = &inspector.BasicAuth{Username: basicAuthParts[0], Password: basicAuthParts[1]}
+	}
+
+	if len(loginURL) > 0 {
+		config.opts.Login = &inspector.LoginFlow{URL: loginURL, Fields: map[string]string{}}
+
+		for _, loginField := range loginFields {
+			fieldParts := strings.SplitN(loginField, "=", 2)
+			if len(fieldParts) != 2 {
+				return fmt.Errorf("invalid -login-field %q, expected name=value", loginField)
+			}
+			config.opts.Login.Fields[fieldParts[0]] = fieldParts[1]
+		}
+	} else if len(loginFields) > 0 {
+		return fmt.Errorf("-login-field needs -login-url")
+	}
+
+	return nil
+}
+
+func inspect(inputURL string, config *cliConfig) *cliResult {
+	return finish(inspector.InspectURLWithOptions(inputURL, config.options()), config)
+}
+
+func inspectHTML(document []byte, baseURL string, config *cliConfig) *cliResult {
+	return finish(inspector.InspectHTML(bytes.NewReader(document), baseURL, config.options()), config)
+}
+
+// options returns the inspector options of one inspection, which starts now
+func (config *cliConfig) options() *inspector.Options {
+	opts := config.opts
+
+	deadline := time.Now().Add(config.timeout)
+	if config.linkCheck {
+		opts.LinkAnalyticsDeadline = &deadline
+	} else if opts.Timeout == 0 {
+		opts.Timeout = config.timeout
+	}
+
+	return &opts
+}
+
+// finish waits for the link analysis, and checks the report
+func finish(report *inspector.InspectReport, config *cliConfig) *cliResult {
+	report.LinkAnalyticWG.Wait()
+	report.FinishLinkAnalysis()
+	report.CountLinks()
+
+	return &cliResult{Report: report, Failures: config.check(report)}
+}
+
+// check returns the checks the report fails
+func (config *cliConfig) check(report *inspector.InspectReport) []string {
+	failures := []string{}
+
+	if config.failOnPageError && (report.Error != nil || report.StatusCode >= 400) {
+		failures = append(failures, "page returned "+report.StatusMsg)
+		return failures
+	}
+
+	// A challenge page is not the content of the page, even with a success status
+	if config.failOnPageError && report.Challenge != nil {
+		failures = append(failures, "page returned a "+report.Challenge.Provider+" bot challenge")
+		return failures
+	}
+
+	if config.requireTitle && (report.PageTitle == "Not defined" || len(strings.TrimSpace(report.PageTitle)) == 0) {
+		failures = append(failures, "page has no title")
+	}
+
+	if config.failOnBrokenInternal {
+		if brokenInternal := countProblems(report, inspector.ProblemBrokenInternalLink); brokenInternal > 0 {
+			failures = append(failures, fmt.Sprintf("%d broken internal links", brokenInternal))
+		}
+	}
+
+	if config.failOnMixedContent {
+		if activeMixedContent := countMixedContent(report, inspector.MixedContentActive); activeMixedContent > 0 {
+			failures = append(failures, fmt.Sprintf("%d active mixed content", activeMixedContent))
+		}
+	}
+
+	if config.maxInaccessibleLinks >= 0 && report.InaccessibleLinkCount > config.maxInaccessibleLinks {
+		failures = append(failures, fmt.Sprintf("%d inaccessible links, more than %d", report.InaccessibleLinkCount, config.maxInaccessibleLinks))
+	}
+
+	if config.maxUnfinishedLinks >= 0 && report.UnfinishedLinkCount > config.maxUnfinishedLinks {
+		failures = append(failures, fmt.Sprintf("%d links could not be checked in time, more than %d", report.UnfinishedLinkCount, config.maxUnfinishedLinks))
+	}
+
+	return failures
+}
+
+func readDocument(path string, stdin io.Reader) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(stdin)
+	}
+	return os.ReadFile(path)
+}
+
+func countMixedContent(report *inspector.InspectReport, kind string) int {
+	count := 0
+	for _, mixedContent := range report.MixedContent {
+		if mixedContent.Kind == kind {
+			count++
+		}
+	}
+	return count
+}
+
+func countProblems(report *inspector.InspectReport, code string) int {
+	count := 0
+	for _, problem := range report.Problems() {
+		if problem.Code == code {
+			count++
+		}
+	}
+	return count
+}
+
+func printResult(stdout io.Writer, result *cliResult) {
+	report := result.Report
+	table := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(table, "URL\t%s\n", report.URL)
+	fmt.Fprintf(table, "Status\t%d %s\n", report.StatusCode, strings.TrimPrefix(report.StatusMsg, fmt.Sprint(report.StatusCode)+" "))
+
+	if report.Error != nil {
+		fmt.Fprintf(table, "Error\t%s\n", report.Error.Code)
+	} else {
+		fmt.Fprintf(table, "HTML version\t%s\n", report.HTMLVersion)
+		fmt.Fprintf(table, "Title\t%s\n", report.PageTitle)
+		fmt.Fprintf(table, "Headings\t%s\n", formatHeadings(report.Headings))
+		fmt.Fprintf(table, "Login fields\t%d\n", report.LoginFieldCount)
+		fmt.Fprintf(table, "Sign in\t%s\n", formatAuthMethods(report))
+		if len(report.Captchas) > 0 {
+			fmt.Fprintf(table, "CAPTCHAs\t%s\n", strings.Join(report.Captchas, ", "))
+		}
+		if report.Challenge != nil {
+			fmt.Fprintf(table, "Challenge\t%s (%s)\n", report.Challenge.Provider, report.Challenge.Reason)
+		}
+		if len(report.MixedContent) > 0 {
+			fmt.Fprintf(table, "Mixed content\t%d active, %d passive, %d navigational\n", countMixedContent(report, inspector.MixedContentActive),
+				countMixedContent(report, inspector.MixedContentPassive), countMixedContent(report, inspector.MixedContentNavigational))
+			for _, mixedContent := range report.MixedContent {
+				if mixedContent.Kind == inspector.MixedContentActive {
+					fmt.Fprintf(table, "  %s\t%s\n", mixedContent.Tag, mixedContent.URL)
+				}
+			}
+		}
+		if report.InsecureTLS {
+			fmt.Fprintf(table, "Insecure TLS\tcertificates were not verified\n")
+		}
+		if report.TLS != nil {
+			fmt.Fprintf(table, "TLS\t%s, certificate expires in %d days\n", report.TLS.Version, report.TLS.DaysRemaining)
+			for _, issue := range report.TLS.Issues {
+				fmt.Fprintf(table, "  %s\t%s: %s\n", issue.Code, report.TLS.Host, issue.Message)
+			}
+		}
+		for _, host := range sortedLinkTLSHosts(report) {
+			for _, issue := range report.LinkTLS[host].Issues {
+				fmt.Fprintf(table, "  %s\t%s: %s\n", issue.Code, host, issue.Message)
+			}
+		}
+		for _, cookie := range report.Cookies {
+			for _, issue := range cookie.Issues {
+				fmt.Fprintf(table, "  cookie\t%s: %s\n", cookie.Name, issue.Message)
+			}
+		}
+		if audit := report.SecurityHeaders; audit != nil {
+			fmt.Fprintf(table, "Security headers\t%s (%d/100)\n", audit.Grade, audit.Score)
+			for _, securityHeader := range audit.Headers {
+				for _, finding := range securityHeader.Findings {
+					fmt.Fprintf(table, "  %s\t%s: %s\n", securityHeader.Status, securityHeader.Name, finding)
+				}
+			}
+		}
+		fmt.Fprintf(table, "Links\t%d total, %d internal, %d external\n", report.TotalLinkCount, report.InternalLinkCount, report.ExternalLinkCount)
+		fmt.Fprintf(table, "Link checks\t%d accessible, %d inaccessible, %d not analysed, %d unfinished\n",
+			report.AccessibleLinkCount, report.InaccessibleLinkCount, report.NotAnalysedLinkCount, report.UnfinishedLinkCount)
+
+		if sample := report.LinkSample; sample != nil && sample.Overall != nil {
+			fmt.Fprintf(table, "Estimated broken\t%.1f%% (95%% CI %.1f%% - %.1f%%) of %d links\n",
+				sample.Overall.BrokenRate*100, sample.Overall.BrokenRateLow*100, sample.Overall.BrokenRateHigh*100, sample.Overall.Population)
+		}
+	}
+
+	for _, lnk := range report.Links {
+		if lnk.StatusCode >= 400 {
+			fmt.Fprintf(table, "  %d\t%s (%s)\n", lnk.StatusCode, lnk.URL, lnk.Type)
+		}
+	}
+
+	if len(result.Failures) == 0 {
+		fmt.Fprintf(table, "Result\tPASS\n")
+	} else {
+		fmt.Fprintf(table, "Result\tFAIL: %s\n", strings.Join(result.Failures, "; "))
+	}
+
+	table.Flush()
+	fmt.Fprintln(stdout)
+}
+
+// sortedLinkTLSHosts returns the hosts of the TLS details of the links, in a stable order
+func sortedLinkTLSHosts(report *inspector.InspectReport) []string {
+	hosts := []string{}
+	for host := range report.LinkTLS {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+func formatAuthMethods(report *inspector.InspectReport) string {
+	if len(report.AuthMethods) == 0 {
+		return "none"
+	}
+
+	methods := strings.Join(report.AuthMethods, ", ")
+	if len(report.SignInProviders) > 0 {
+		methods += " (" + strings.Join(report.SignInProviders, ", ") + ")"
+	}
+	return methods
+}
+
+func formatHeadings(headings map[string][]string) string {
+	levels := []string{}
+	for level := range headings {
+		levels = append(levels, level)
+	}
+	sort.Strings(levels)
+
+	counts := []string{}
+	for _, level := range levels {
+		counts = append(counts, fmt.Sprintf("%s: %d", level, len(headings[level])))
+	}
+
+	if len(counts) == 0 {
+		return "none"
+	}
+	return strings.Join(counts, ", ")
+}
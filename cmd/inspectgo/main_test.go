@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/ok":
+			wr.Write([]byte(`<html><head><title>OK</title></head><body><a href="/ok">self</a></body></html>`))
+		case "/broken":
+			wr.Write([]byte(`<html><head><title>Broken</title></head><body><a href="/missing">missing</a></body></html>`))
+		case "/untitled":
+			wr.Write([]byte(`<html><body><a href="/ok">ok</a></body></html>`))
+		case "/slow-links":
+			wr.Write([]byte(`<html><head><title>Slow</title></head><body><a href="/slow">slow</a></body></html>`))
+		case "/slow":
+			select {
+			case <-req.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		case "/error":
+			http.Error(wr, "failed", http.StatusInternalServerError)
+		default:
+			http.NotFound(wr, req)
+		}
+	}))
+}
+
+func runCLI(args []string, stdin string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	exitCode := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return exitCode, stdout.String(), stderr.String()
+}
+
+func TestRunFlags(t *testing.T) {
+	usageErrors := [][]string{
+		{},
+		{"-no-such-flag", "https://example.com"},
+		{"ftp://example.com"},
+		{"-login-field", "username=me", "https://example.com"},
+		{"-header", "no colon", "https://example.com"},
+		{"-allow-networks", "not a network", "https://example.com"},
+		{"-link-types", "unknown", "https://example.com"},
+		{"-html", "-", "https://example.com", "https://example.org"},
+	}
+
+	for _, args := range usageErrors {
+		if exitCode, _, stderr := runCLI(args, ""); exitCode != exitUsage || len(stderr) == 0 {
+			t.Errorf("args %q returned exit code %d with output %q, expected %d with an error", args, exitCode, stderr, exitUsage)
+		}
+	}
+
+	exitCode, _, stderr := runCLI([]string{"-h"}, "")
+	if exitCode != exitOK || !strings.Contains(stderr, "Usage: inspectgo") {
+		t.Errorf("-h returned exit code %d with output %q, expected %d with the usage", exitCode, stderr, exitOK)
+	}
+}
+
+func TestRunChecks(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	local := []string{"-allow-networks", "127.0.0.0/8"}
+
+	cases := []struct {
+		args             []string
+		expectedExitCode int
+		expectedFailure  string
+	}{
+		{[]string{server.URL + "/ok"}, exitOK, ""},
+		{[]string{"-require-title", server.URL + "/ok"}, exitOK, ""},
+		{[]string{"-require-title", server.URL + "/untitled"}, exitCheckFailed, "page has no title"},
+		{[]string{server.URL + "/error"}, exitCheckFailed, "page returned 500"},
+		{[]string{"-fail-on-page-error=false", server.URL + "/error"}, exitOK, ""},
+		{[]string{server.URL + "/broken"}, exitOK, ""},
+		{[]string{"-fail-on-broken-internal", server.URL + "/broken"}, exitCheckFailed, "1 broken internal links"},
+		{[]string{"-fail-on-broken-internal", "-link-check=false", server.URL + "/broken"}, exitOK, ""},
+		{[]string{"-max-inaccessible", "1", server.URL + "/broken"}, exitOK, ""},
+		{[]string{"-max-inaccessible", "0", server.URL + "/broken"}, exitCheckFailed, "1 inaccessible links, more than 0"},
+		{[]string{"-max-unfinished", "0", "-timeout", "1s", server.URL + "/slow-links"}, exitCheckFailed, "1 links could not be checked in time, more than 0"},
+		{[]string{"-max-unfinished", "1", "-timeout", "1s", server.URL + "/slow-links"}, exitOK, ""},
+
+		// One failing page fails the run
+		{[]string{"-require-title", server.URL + "/ok", server.URL + "/untitled"}, exitCheckFailed, "page has no title"},
+	}
+
+	for _, testCase := range cases {
+		args := append(append([]string{}, local...), testCase.args...)
+
+		exitCode, stdout, stderr := runCLI(args, "")
+		if exitCode != testCase.expectedExitCode {
+			t.Errorf("args %q returned exit code %d, expected %d. Output: %s %s", testCase.args, exitCode, testCase.expectedExitCode, stdout, stderr)
+		}
+		if len(testCase.expectedFailure) > 0 && !strings.Contains(stdout, testCase.expectedFailure) {
+			t.Errorf("args %q did not report the failure %q. Output: %s", testCase.args, testCase.expectedFailure, stdout)
+		}
+	}
+}
+
+func TestRunHTML(t *testing.T) {
+	document := `<html><head><title>Build</title><script src="http://cdn.example.com/app.js"></script></head><body><img src="http://cdn.example.com/logo.png"></body></html>`
+
+	exitCode, stdout, _ := runCLI([]string{"-link-check=false", "-html", "-", "https://example.com"}, document)
+	if exitCode != exitOK {
+		t.Errorf("returned exit code %d without -fail-on-mixed-content, expected %d. Output: %s", exitCode, exitOK, stdout)
+	}
+
+	exitCode, stdout, _ = runCLI([]string{"-link-check=false", "-fail-on-mixed-content", "-html", "-", "https://example.com"}, document)
+	if exitCode != exitCheckFailed || !strings.Contains(stdout, "1 active mixed content") {
+		t.Errorf("returned exit code %d with -fail-on-mixed-content, expected %d for the script only. Output: %s", exitCode, exitCheckFailed, stdout)
+	}
+
+	exitCode, stdout, _ = runCLI([]string{"-json", "-link-check=false", "-html", "-", "https://example.com"}, document)
+
+	results := []*cliResult{}
+	if decodeErr := json.Unmarshal([]byte(stdout), &results); decodeErr != nil || exitCode != exitOK {
+		t.Fatalf("returned exit code %d and JSON error %v, expected %d and a JSON report", exitCode, decodeErr, exitOK)
+	}
+	if len(results) != 1 || results[0].Report.PageTitle != "Build" || len(results[0].Failures) != 0 {
+		t.Errorf("returned JSON results %+v, expected one passing report titled Build", results)
+	}
+}
@@ -2,17 +2,32 @@ export interface InspectResponse {
   url: string;
   status_code: number;
   status_msg: string;
+  error?: InspectError;
   html_version: string;
   page_title: string;
   headings: Headings;
   login_field_count: number;
+  auth_methods: string[];
+  sign_in_providers: string[];
+  captchas: string[];
+  challenge?: Challenge;
+  security_headers?: SecurityHeaderAudit;
+  cookies: InspectedCookie[];
+  insecure_tls?: boolean;
+  tls?: TLSDetails;
+  link_tls?: { [host: string]: TLSDetails };
+  mixed_content: MixedContent[];
+  forms: InspectedForm[];
   links: Link[];
   accessible_link_count: number;
   inaccessible_link_count: number;
   not_analysed_link_count: number;
+  unfinished_link_count: number;
   total_link_count: number;
   external_link_count: number;
   internal_link_count: number;
+  link_sample?: LinkSample;
+  continuation?: string;
 }
 
 export interface Headings {
@@ -29,5 +44,112 @@ export interface Link {
   text: string;
   type: string;
   status_code: number;
+  unfinished?: boolean;
+  section?: string;
+  stratum?: string;
 }
 
+export interface InspectedForm {
+  kind: string;
+  action: string;
+  method: string;
+  enctype: string;
+  fields: FormField[];
+  submit_texts: string[];
+  issues: Problem[];
+}
+
+export interface Challenge {
+  provider: string;
+  reason: string;
+}
+
+export interface SecurityHeaderAudit {
+  grade: string;
+  score: number;
+  headers: SecurityHeader[];
+}
+
+export interface SecurityHeader {
+  name: string;
+  value?: string;
+  status: string;
+  findings: string[];
+}
+
+export interface InspectedCookie {
+  name: string;
+  domain?: string;
+  path?: string;
+  secure: boolean;
+  http_only: boolean;
+  same_site?: string;
+  expires?: string;
+  deleted?: boolean;
+  size: number;
+  set_by: string;
+  session_like: boolean;
+  issues: Problem[];
+}
+
+export interface TLSDetails {
+  host: string;
+  version?: string;
+  cipher_suite?: string;
+  certificates: CertificateDetails[];
+  expires: string;
+  days_remaining: number;
+  issues: Problem[];
+}
+
+export interface CertificateDetails {
+  subject: string;
+  issuer: string;
+  not_before: string;
+  not_after: string;
+  sans?: string[];
+}
+
+export interface MixedContent {
+  url: string;
+  tag: string;
+  attribute: string;
+  kind: string;
+}
+
+export interface Problem {
+  code: string;
+  message: string;
+}
+
+export interface FormField {
+  name: string;
+  type: string;
+  required: boolean;
+  autocomplete?: string;
+  label?: string;
+}
+
+export interface InspectError {
+  code: string;
+  message: string;
+  details?: { [key: string]: string };
+  retryable: boolean;
+}
+
+export interface LinkSample {
+  sample_size: number;
+  strata_population: { [stratum: string]: number };
+  overall: LinkEstimate;
+  by_type: { [type: string]: LinkEstimate };
+}
+
+export interface LinkEstimate {
+  population: number;
+  checked: number;
+  broken: number;
+  broken_rate: number;
+  broken_rate_low: number;
+  broken_rate_high: number;
+  estimated_broken_links: number;
+}
@@ -0,0 +1,225 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/HasinduLanka/InspectGo/pkg/inspector"
+)
+
+const (
+	// Inspect web pages without analysing their links
+	ScopeInspect = "inspect"
+
+	// Analyse the links of inspected web pages, which sends requests to other websites
+	ScopeCrawl = "crawl"
+
+	// Everything, including the scopes above
+	ScopeAdmin = "admin"
+)
+
+var KnownScopes = []string{ScopeInspect, ScopeCrawl, ScopeAdmin}
+
+// Key is an API key and what it may access
+type Key struct {
+	// Name used to attribute requests in logs. Never log the key itself
+	Name   string   `json:"name"`
+	Key    string   `json:"key"`
+	Scopes []string `json:"scopes"`
+
+	// Weight of this key's inspections when they share link analysers with others. See inspector.Options.Priority
+	Priority int `json:"priority"`
+}
+
+// HasScope reports whether the key may access the scope. Admin keys may access everything
+func (key *Key) HasScope(scope string) bool {
+	for _, keyScope := range key.Scopes {
+		if keyScope == scope || keyScope == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyStore holds the API keys of a deployment
+type KeyStore struct {
+	// Keys by the SHA-256 hash of the key, so lookups don't leak the key through timing
+	keys map[[sha256.Size]byte]*Key
+}
+
+// NewKeyStore validates the keys and returns a store holding them
+func NewKeyStore(keys []*Key) (*KeyStore, error) {
+	store := &KeyStore{keys: map[[sha256.Size]byte]*Key{}}
+
+	for _, key := range keys {
+		if len(key.Name) == 0 || len(key.Key) == 0 {
+			return nil, errors.New("every API key needs a name and a key")
+		}
+
+		for _, scope := range key.Scopes {
+			if !isKnownScope(scope) {
+				return nil, fmt.Errorf("API key %s has unknown scope %q", key.Name, scope)
+			}
+		}
+
+		keyHash := sha256.Sum256([]byte(key.Key))
+		if _, isDuplicate := store.keys[keyHash]; isDuplicate {
+			return nil, fmt.Errorf("API key %s is a duplicate", key.Name)
+		}
+
+		store.keys[keyHash] = key
+	}
+
+	return store, nil
+}
+
+// LoadKeyFile reads API keys from a JSON file: [{"name": "team-a", "key": "secret", "scopes": ["inspect", "crawl"], "priority": 2}]
+func LoadKeyFile(path string) (*KeyStore, error) {
+	keyFile, readErr := os.ReadFile(path)
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	var keys []*Key
+	if unmarshalErr := json.Unmarshal(keyFile, &keys); unmarshalErr != nil {
+		return nil, unmarshalErr
+	}
+
+	return NewKeyStore(keys)
+}
+
+// ParseKeys reads API keys from a comma separated list of name:key:scope|scope entries,
+// such as "team-a:secret-a:inspect|crawl,ops:secret-b:admin"
+func ParseKeys(keyList string) (*KeyStore, error) {
+	keys := []*Key{}
+
+	for _, entry := range strings.Split(keyList, ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+
+		entryParts := strings.Split(entry, ":")
+		if len(entryParts) != 3 {
+			return nil, fmt.Errorf("API key entry %q is not in name:key:scope|scope format", entryParts[0])
+		}
+
+		keys = append(keys, &Key{
+			Name:   entryParts[0],
+			Key:    entryParts[1],
+			Scopes: strings.Split(entryParts[2], "|"),
+		})
+	}
+
+	return NewKeyStore(keys)
+}
+
+// KeyStoreFromEnv loads API keys from the file at INSPECTOR_API_KEYS_FILE, or from INSPECTOR_API_KEYS.
+// It returns nil if neither is set, which means authentication is disabled.
+func KeyStoreFromEnv() (*KeyStore, error) {
+	if keyFilePath := os.Getenv(`INSPECTOR_API_KEYS_FILE`); len(keyFilePath) > 0 {
+		return LoadKeyFile(keyFilePath)
+	}
+
+	if keyList := os.Getenv(`INSPECTOR_API_KEYS`); len(keyList) > 0 {
+		return ParseKeys(keyList)
+	}
+
+	return nil, nil
+}
+
+// Authenticate returns the key of the request, from the X-API-Key header or an Authorization: Bearer header
+func (store *KeyStore) Authenticate(req *http.Request) (*Key, bool) {
+	presentedKey := req.Header.Get("X-API-Key")
+
+	if authorization := req.Header.Get("Authorization"); len(presentedKey) == 0 && strings.HasPrefix(authorization, "Bearer ") {
+		presentedKey = strings.TrimPrefix(authorization, "Bearer ")
+	}
+
+	if len(presentedKey) == 0 {
+		return nil, false
+	}
+
+	key, isKnown := store.keys[sha256.Sum256([]byte(presentedKey))]
+	return key, isKnown
+}
+
+// Require authenticates the request and checks that its key has the scope.
+// If not, it responds with status 401 or 403 and returns false.
+// Otherwise it returns the request with the key attached to its context. See KeyFromContext.
+//
+// A nil store means authentication is disabled, and every request is allowed anonymously.
+func (store *KeyStore) Require(wr http.ResponseWriter, req *http.Request, scope string) (*http.Request, bool) {
+	if store == nil {
+		return req, true
+	}
+
+	key, isAuthenticated := store.Authenticate(req)
+	if !isAuthenticated {
+		wr.Header().Set("WWW-Authenticate", `Bearer realm="InspectGo"`)
+		inspector.WriteError(wr, http.StatusUnauthorized, inspector.NewInspectError(inspector.ErrorCodeUnauthorized, "A valid API key is required in the X-API-Key header", false, nil))
+		return req, false
+	}
+
+	if !key.HasScope(scope) {
+		inspector.WriteError(wr, http.StatusForbidden, inspector.NewInspectError(inspector.ErrorCodeForbidden, "The API key does not have the "+scope+" scope", false, nil))
+		return req, false
+	}
+
+	return req.WithContext(WithKey(req.Context(), key)), true
+}
+
+// Middleware requires the scope for every request of the handler
+func (store *KeyStore) Middleware(scope string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		req, isAllowed := store.Require(wr, req, scope)
+		if !isAllowed {
+			return
+		}
+
+		next.ServeHTTP(wr, req)
+	})
+}
+
+type contextKey struct{}
+
+// WithKey attaches the key to the context
+func WithKey(ctx context.Context, key *Key) context.Context {
+	return context.WithValue(ctx, contextKey{}, key)
+}
+
+// KeyFromContext returns the key attached to the context, or nil for anonymous requests
+func KeyFromContext(ctx context.Context) *Key {
+	key, _ := ctx.Value(contextKey{}).(*Key)
+	return key
+}
+
+// HasScope reports whether the request may access the scope.
+// Anonymous requests may access everything, as they are only allowed when authentication is disabled
+func HasScope(ctx context.Context, scope string) bool {
+	key := KeyFromContext(ctx)
+	return key == nil || key.HasScope(scope)
+}
+
+// ClientName returns the name of the key of the request for logs, or "anonymous"
+func ClientName(ctx context.Context) string {
+	if key := KeyFromContext(ctx); key != nil {
+		return key.Name
+	}
+	return "anonymous"
+}
+
+func isKnownScope(scope string) bool {
+	for _, knownScope := range KnownScopes {
+		if scope == knownScope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseKeys(t *testing.T) {
+	store, parseErr := ParseKeys("team-a:secret-a:inspect|crawl, ops:secret-b:admin")
+	if parseErr != nil {
+		t.Fatal(parseErr)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/inspect", nil)
+	req.Header.Set("Authorization", "Bearer secret-b")
+
+	key, isAuthenticated := store.Authenticate(req)
+	if !isAuthenticated || key.Name != "ops" || !key.HasScope(ScopeCrawl) {
+		t.Errorf("bearer token authenticated as %+v, expected the ops admin key", key)
+	}
+
+	invalidKeyLists := []string{"team-a:secret-a", "team-a:secret-a:everything", ":secret-a:inspect", "a:same:inspect,b:same:crawl"}
+	for _, keyList := range invalidKeyLists {
+		if _, parseErr := ParseKeys(keyList); parseErr == nil {
+			t.Errorf("key list %q was parsed without an error", keyList)
+		}
+	}
+}
+
+func TestRequire(t *testing.T) {
+	store, _ := ParseKeys("reader:secret-a:inspect")
+
+	var handledKey *Key
+	handler := store.Middleware(ScopeInspect, http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		handledKey = KeyFromContext(req.Context())
+	}))
+
+	expectedStatusCodes := map[string]int{
+		"":         http.StatusUnauthorized,
+		"wrong":    http.StatusUnauthorized,
+		"secret-a": http.StatusOK,
+	}
+
+	for presentedKey, expectedStatusCode := range expectedStatusCodes {
+		req := httptest.NewRequest(http.MethodPost, "/api/inspect", nil)
+		req.Header.Set("X-API-Key", presentedKey)
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+
+		if recorder.Code != expectedStatusCode {
+			t.Errorf("key %q returned status code %d, expected %d", presentedKey, recorder.Code, expectedStatusCode)
+		}
+	}
+
+	if handledKey == nil || handledKey.Name != "reader" {
+		t.Errorf("handler received key %+v, expected the reader key", handledKey)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/inspect", nil)
+	req.Header.Set("X-API-Key", "secret-a")
+	recorder := httptest.NewRecorder()
+
+	if _, isAllowed := store.Require(recorder, req, ScopeCrawl); isAllowed || recorder.Code != http.StatusForbidden {
+		t.Errorf("key without the crawl scope returned status code %d, expected %d", recorder.Code, http.StatusForbidden)
+	}
+
+	// Without a key store, authentication is disabled
+	var disabledStore *KeyStore
+	if req, isAllowed := disabledStore.Require(httptest.NewRecorder(), req, ScopeAdmin); !isAllowed || !HasScope(req.Context(), ScopeAdmin) {
+		t.Errorf("anonymous request was refused without a key store")
+	}
+}
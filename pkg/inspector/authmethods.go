@@ -0,0 +1,202 @@
+package inspector
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Ways to sign in offered by a page. See InspectReport.AuthMethods
+const (
+	AuthMethodPassword    = "password"
+	AuthMethodPasskey     = "passkey"
+	AuthMethodOneTimeCode = "one_time_code"
+	AuthMethodMagicLink   = "magic_link"
+
+	// Sign in with an identity provider such as Google or GitHub. See InspectReport.SignInProviders
+	AuthMethodThirdParty = "third_party"
+)
+
+// signInProvider is an identity provider recognized by its name in button texts, its sign in URLs and its widgets
+type signInProvider struct {
+	name string
+
+	// Hosts, or hosts and a part of the path, of the authorization pages and sign in scripts.
+	// Subdomains of the hosts also match
+	urls []string
+
+	// Parts of the ids and classes of the sign in widgets
+	widgets []string
+}
+
+var signInProviders = []signInProvider{
+	{"google", []string{"accounts.google.com"}, []string{"g_id_onload", "g_id_signin", "google-signin"}},
+	{"github", []string{"github.com/login/oauth"}, nil},
+	{"apple", []string{"appleid.apple.com", "appleid.cdn-apple.com"}, []string{"appleid-signin"}},
+	{"microsoft", []string{"login.microsoftonline.com", "login.live.com"}, nil},
+	{"facebook", []string{"facebook.com/dialog/oauth", "connect.facebook.net"}, []string{"fb-login-button"}},
+}
+
+// Words of buttons and links that start a sign in with a provider, such as "Continue with Google"
+var signInVerbs = []string{"sign in", "signin", "log in", "login", "sign up", "signup", "continue", "connect"}
+
+// Words of buttons and links of passwordless sign in
+var (
+	passkeyKeywords   = []string{"passkey", "security key"}
+	magicLinkKeywords = []string{"magic link", "email me a link", "send me a link", "sign-in link", "sign in link", "login link", "log in link", "passwordless"}
+)
+
+// Names of one-time code fields
+var oneTimeCodeFieldNames = []string{"otp", "totp", "one_time_code", "verification_code", "2fa", "mfa_code"}
+
+// Calls to the WebAuthn API in scripts
+var webAuthnScriptCalls = []string{"navigator.credentials.get", "navigator.credentials.create", "publickeycredential"}
+
+// addAuthMethod adds a way to sign in once
+func (report *InspectReport) addAuthMethod(method string) {
+	for _, existingMethod := range report.AuthMethods {
+		if existingMethod == method {
+			return
+		}
+	}
+	report.AuthMethods = append(report.AuthMethods, method)
+}
+
+// addSignInProvider adds an identity provider once
+func (report *InspectReport) addSignInProvider(provider string) {
+	report.addAuthMethod(AuthMethodThirdParty)
+
+	for _, existingProvider := range report.SignInProviders {
+		if existingProvider == provider {
+			return
+		}
+	}
+	report.SignInProviders = append(report.SignInProviders, provider)
+}
+
+// detectAuthInput detects password, one-time code and passkey fields
+func (report *InspectReport) detectAuthInput(tkn *html.Token) {
+	inputType := strings.ToLower(tokenAttribute(tkn, "type"))
+	inputName := strings.ToLower(tokenAttribute(tkn, "name"))
+	autocomplete := strings.ToLower(tokenAttribute(tkn, "autocomplete"))
+
+	if inputType == "password" {
+		report.addAuthMethod(AuthMethodPassword)
+	}
+
+	// Browsers offer passkeys for fields with autocomplete="username webauthn"
+	if strings.Contains(autocomplete, "webauthn") {
+		report.addAuthMethod(AuthMethodPasskey)
+	}
+
+	if strings.Contains(autocomplete, "one-time-code") {
+		report.addAuthMethod(AuthMethodOneTimeCode)
+		return
+	}
+	if inputType == "hidden" {
+		return
+	}
+	for _, fieldName := range oneTimeCodeFieldNames {
+		if inputName == fieldName || strings.HasPrefix(inputName, fieldName+"_") || strings.HasSuffix(inputName, "_"+fieldName) {
+			report.addAuthMethod(AuthMethodOneTimeCode)
+			return
+		}
+	}
+}
+
+// detectAuthAttributes detects sign in widgets by the id and class of any element,
+// and sign in with a provider by the URLs of links, forms and scripts
+func (report *InspectReport) detectAuthAttributes(tkn *html.Token) {
+	identity := strings.ToLower(tokenAttribute(tkn, "id") + " " + tokenAttribute(tkn, "class"))
+
+	var elementURL string
+	for _, attrName := range []string{"href", "action", "src"} {
+		if elementURL = tokenAttribute(tkn, attrName); len(elementURL) > 0 {
+			break
+		}
+	}
+
+	var parsedURL *url.URL
+	if len(elementURL) > 0 {
+		parsedURL, _ = url.Parse(strings.TrimSpace(elementURL))
+	}
+
+	for _, provider := range signInProviders {
+		for _, widget := range provider.widgets {
+			if strings.Contains(identity, widget) {
+				report.addSignInProvider(provider.name)
+			}
+		}
+
+		if parsedURL == nil || len(parsedURL.Host) == 0 {
+			continue
+		}
+		for _, providerURL := range provider.urls {
+			if matchesProviderURL(parsedURL, providerURL) {
+				report.addSignInProvider(provider.name)
+			}
+		}
+	}
+}
+
+// matchesProviderURL checks if the host of elementURL is the host of providerURL or its subdomain,
+// and its path contains the path of providerURL, such as /v18.0/dialog/oauth for facebook.com/dialog/oauth
+func matchesProviderURL(elementURL *url.URL, providerURL string) bool {
+	providerParts := strings.SplitN(providerURL, "/", 2)
+	host := strings.ToLower(elementURL.Hostname())
+
+	if host != providerParts[0] && !strings.HasSuffix(host, "."+providerParts[0]) {
+		return false
+	}
+	return len(providerParts) == 1 || strings.Contains(strings.ToLower(elementURL.Path), "/"+providerParts[1])
+}
+
+// detectAuthText detects sign in options by the text of a button or link
+func (report *InspectReport) detectAuthText(text string) {
+	text = strings.ToLower(text)
+	if len(text) == 0 {
+		return
+	}
+
+	for _, keyword := range passkeyKeywords {
+		if strings.Contains(text, keyword) {
+			report.addAuthMethod(AuthMethodPasskey)
+		}
+	}
+
+	for _, keyword := range magicLinkKeywords {
+		if strings.Contains(text, keyword) {
+			report.addAuthMethod(AuthMethodMagicLink)
+		}
+	}
+
+	hasSignInVerb := false
+	for _, verb := range signInVerbs {
+		if strings.Contains(text, verb) {
+			hasSignInVerb = true
+			break
+		}
+	}
+	if !hasSignInVerb {
+		return
+	}
+
+	for _, provider := range signInProviders {
+		if strings.Contains(text, "with "+provider.name) {
+			report.addSignInProvider(provider.name)
+		}
+	}
+}
+
+// detectAuthScript detects calls to the WebAuthn API in an inline script
+func (report *InspectReport) detectAuthScript(script string) {
+	script = strings.ToLower(script)
+
+	for _, call := range webAuthnScriptCalls {
+		if strings.Contains(script, call) {
+			report.addAuthMethod(AuthMethodPasskey)
+			return
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package inspector
+
+import (
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDetectAuthMethods(t *testing.T) {
+	tests := []struct {
+		name              string
+		page              string
+		expectedMethods   []string
+		expectedProviders []string
+	}{
+		{"password", `<form><input name="email"><input type="password" name="password"></form>`, []string{AuthMethodPassword}, []string{}},
+		{"passkey field", `<input name="username" autocomplete="username webauthn">`, []string{AuthMethodPasskey}, []string{}},
+		{"passkey script", `<button>Continue</button><script>navigator.credentials.get({publicKey: options})</script>`, []string{AuthMethodPasskey}, []string{}},
+		{"one-time code", `<form><input name="code" autocomplete="one-time-code"></form>`, []string{AuthMethodOneTimeCode}, []string{}},
+		{"magic link", `<form><input type="email" name="email"><button>Email me a link</button></form>`, []string{AuthMethodMagicLink}, []string{}},
+		{"provider buttons", `<button>Continue with Google</button><a href="/auth/github">Sign in with GitHub</a>`, []string{AuthMethodThirdParty}, []string{"google", "github"}},
+		{"provider urls", `<a href="https://www.facebook.com/v18.0/dialog/oauth?client_id=1">Facebook</a><script src="https://appleid.cdn-apple.com/appleauth/static/jsapi/appleid/1/en_US/appleid.auth.js"></script>`, []string{AuthMethodThirdParty}, []string{"facebook", "apple"}},
+		{"provider widget", `<div id="g_id_onload" data-client_id="1"></div>`, []string{AuthMethodThirdParty}, []string{"google"}},
+		{"provider widget inside a button", `<button type="button"><span id="g_id_onload" data-client_id="1"></span></button>`, []string{AuthMethodThirdParty}, []string{"google"}},
+		{"provider url inside a heading", `<h2><a href="/account"><img src="https://appleid.cdn-apple.com/appleauth/static/jsapi/appleid/1/en_US/appleid.auth.js"></a></h2>`, []string{AuthMethodThirdParty}, []string{"apple"}},
+		{"unrelated links", `<a href="https://www.facebook.com/example">Follow us</a><a href="/about">Continue reading</a>`, []string{}, []string{}},
+	}
+
+	// Links are not checked
+	pastDeadline := time.Now()
+
+	for _, test := range tests {
+		report := inspectURLResponse("https://example.com", &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(test.page))}, nil, &Options{LinkAnalyticsDeadline: &pastDeadline})
+
+		if !reflect.DeepEqual(report.AuthMethods, test.expectedMethods) {
+			t.Errorf("%s: returned auth methods %v, expected %v", test.name, report.AuthMethods, test.expectedMethods)
+		}
+		if !reflect.DeepEqual(report.SignInProviders, test.expectedProviders) {
+			t.Errorf("%s: returned providers %v, expected %v", test.name, report.SignInProviders, test.expectedProviders)
+		}
+	}
+}
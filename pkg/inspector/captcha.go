@@ -0,0 +1,137 @@
+package inspector
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// CAPTCHA widgets. See InspectReport.Captchas
+const (
+	CaptchaReCaptcha       = "recaptcha"
+	CaptchaHCaptcha        = "hcaptcha"
+	CaptchaTurnstile       = "turnstile"
+	CaptchaFriendlyCaptcha = "friendly_captcha"
+	CaptchaGeeTest         = "geetest"
+	CaptchaArkose          = "arkose"
+)
+
+// Providers of bot challenge pages. See Challenge.Provider
+const (
+	ChallengeProviderCloudflare = "cloudflare"
+	ChallengeProviderAWSWAF     = "aws_waf"
+	ChallengeProviderDataDome   = "datadome"
+)
+
+// Challenge is set when the inspected URL returned a bot challenge page instead of its content.
+// The rest of the report describes the challenge page
+type Challenge struct {
+	Provider string `json:"provider"`
+
+	// Signal the challenge page was detected by, such as a response header
+	Reason string `json:"reason"`
+}
+
+// captchaWidget is a CAPTCHA recognized by the URLs of its scripts and iframes, and the classes of its containers
+type captchaWidget struct {
+	name string
+
+	// Hosts, or hosts and a part of the path. See matchesProviderURL
+	urls []string
+
+	classes []string
+}
+
+var captchaWidgets = []captchaWidget{
+	{CaptchaReCaptcha, []string{"google.com/recaptcha", "gstatic.com/recaptcha", "recaptcha.net"}, []string{"g-recaptcha"}},
+	{CaptchaHCaptcha, []string{"hcaptcha.com"}, []string{"h-captcha"}},
+	{CaptchaTurnstile, []string{"challenges.cloudflare.com/turnstile"}, []string{"cf-turnstile"}},
+	{CaptchaFriendlyCaptcha, []string{"friendlycaptcha.com"}, []string{"frc-captcha"}},
+	{CaptchaGeeTest, []string{"geetest.com"}, []string{"geetest_"}},
+	{CaptchaArkose, []string{"arkoselabs.com", "funcaptcha.com"}, []string{"arkose"}},
+}
+
+// Titles of challenge pages
+var challengePageTitles = map[string]string{
+	"just a moment...":   ChallengeProviderCloudflare,
+	"attention required": ChallengeProviderCloudflare,
+}
+
+// Path of the scripts and forms of Cloudflare challenges. Normal pages may also load scripts from it,
+// so it is only a signal on error responses
+const cloudflareChallengePath = "/cdn-cgi/challenge-platform/"
+
+// addCaptcha adds a CAPTCHA once
+func (report *InspectReport) addCaptcha(captcha string) {
+	for _, existingCaptcha := range report.Captchas {
+		if existingCaptcha == captcha {
+			return
+		}
+	}
+	report.Captchas = append(report.Captchas, captcha)
+}
+
+// detectCaptcha detects CAPTCHA widgets by the src of scripts and iframes and the class of any element
+func (report *InspectReport) detectCaptcha(tkn *html.Token) {
+	class := strings.ToLower(tokenAttribute(tkn, "class"))
+
+	var parsedSrc *url.URL
+	if src := strings.TrimSpace(tokenAttribute(tkn, "src")); len(src) > 0 {
+		parsedSrc, _ = url.Parse(src)
+	}
+
+	if parsedSrc != nil && strings.HasPrefix(parsedSrc.Path, cloudflareChallengePath) {
+		report.hasChallengeScript = true
+	}
+
+	for _, widget := range captchaWidgets {
+		for _, widgetClass := range widget.classes {
+			if strings.Contains(class, widgetClass) {
+				report.addCaptcha(widget.name)
+			}
+		}
+
+		if parsedSrc == nil || len(parsedSrc.Host) == 0 {
+			continue
+		}
+		for _, widgetURL := range widget.urls {
+			if matchesProviderURL(parsedSrc, widgetURL) {
+				report.addCaptcha(widget.name)
+			}
+		}
+	}
+}
+
+// detectChallenge checks if the response is a bot challenge page, by its headers, title and scripts
+func (report *InspectReport) detectChallenge(httpResp *http.Response) {
+	if strings.EqualFold(httpResp.Header.Get("cf-mitigated"), "challenge") {
+		report.Challenge = &Challenge{ChallengeProviderCloudflare, "cf-mitigated: challenge response header"}
+		return
+	}
+
+	if wafAction := strings.ToLower(httpResp.Header.Get("x-amzn-waf-action")); wafAction == "challenge" || wafAction == "captcha" {
+		report.Challenge = &Challenge{ChallengeProviderAWSWAF, "x-amzn-waf-action: " + wafAction + " response header"}
+		return
+	}
+
+	isErrorResponse := httpResp.StatusCode == http.StatusForbidden || httpResp.StatusCode == http.StatusTooManyRequests || httpResp.StatusCode == http.StatusServiceUnavailable
+
+	if isErrorResponse && len(httpResp.Header.Get("x-datadome")) > 0 {
+		report.Challenge = &Challenge{ChallengeProviderDataDome, "x-datadome response header on status " + httpResp.Status}
+		return
+	}
+
+	pageTitle := strings.ToLower(strings.TrimSpace(report.PageTitle))
+	for title, provider := range challengePageTitles {
+		if strings.HasPrefix(pageTitle, title) {
+			report.Challenge = &Challenge{provider, "page title " + report.PageTitle}
+			return
+		}
+	}
+
+	if isErrorResponse && report.hasChallengeScript {
+		report.Challenge = &Challenge{ChallengeProviderCloudflare, "challenge platform script on status " + httpResp.Status}
+	}
+}
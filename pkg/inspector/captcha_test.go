@@ -0,0 +1,67 @@
+package inspector
+
+import (
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDetectCaptchas(t *testing.T) {
+	page := `<form><div class="g-recaptcha" data-sitekey="key"></div></form>
+		<script src="https://js.hcaptcha.com/1/api.js" async></script>
+		<iframe src="https://challenges.cloudflare.com/turnstile/v0/g/abc"></iframe>
+		<script src="https://www.google.com/recaptcha/api.js"></script>`
+
+	report := inspectURLResponse("https://example.com/signup", &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(page))}, nil, nil)
+
+	expected := []string{CaptchaReCaptcha, CaptchaHCaptcha, CaptchaTurnstile}
+	if !reflect.DeepEqual(report.Captchas, expected) {
+		t.Errorf("returned captchas %v, expected %v", report.Captchas, expected)
+	}
+	if report.Challenge != nil {
+		t.Errorf("detected a challenge %+v on a normal page", report.Challenge)
+	}
+}
+
+func TestDetectCaptchasInsideButtons(t *testing.T) {
+	page := `<form><button type="submit"><div class="h-captcha" data-sitekey="key"></div>Sign up</button></form>
+		<a href="/contact"><iframe src="https://challenges.cloudflare.com/turnstile/v0/g/abc"></iframe></a>`
+
+	report := inspectURLResponse("https://example.com/signup", &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(page))}, nil, nil)
+
+	expected := []string{CaptchaHCaptcha, CaptchaTurnstile}
+	if !reflect.DeepEqual(report.Captchas, expected) {
+		t.Errorf("returned captchas %v, expected %v", report.Captchas, expected)
+	}
+}
+
+func TestDetectChallenge(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		header   http.Header
+		page     string
+		expected string
+	}{
+		{"cf-mitigated", 403, http.Header{"Cf-Mitigated": {"challenge"}}, `<title>Example</title>`, ChallengeProviderCloudflare},
+		{"title", 503, http.Header{}, `<title>Just a moment...</title>`, ChallengeProviderCloudflare},
+		{"challenge script", 403, http.Header{}, `<script src="/cdn-cgi/challenge-platform/h/b/orchestrate/chl_page/v1"></script>`, ChallengeProviderCloudflare},
+		{"aws waf", 202, http.Header{"X-Amzn-Waf-Action": {"challenge"}}, ``, ChallengeProviderAWSWAF},
+		{"datadome", 403, http.Header{"X-Datadome": {"protected"}}, ``, ChallengeProviderDataDome},
+		{"bot management script on a normal page", 200, http.Header{}, `<title>Example</title><script src="/cdn-cgi/challenge-platform/scripts/jsd/main.js"></script>`, ""},
+	}
+
+	for _, test := range tests {
+		report := inspectURLResponse("https://example.com", &http.Response{StatusCode: test.status, Header: test.header, Body: io.NopCloser(strings.NewReader(test.page))}, nil, nil)
+
+		provider := ""
+		if report.Challenge != nil {
+			provider = report.Challenge.Provider
+		}
+		if provider != test.expected {
+			t.Errorf("%s: detected challenge %+v, expected %q", test.name, report.Challenge, test.expected)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package inspector
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Continuation tokens older than this are rejected
+var ContinuationTokenLifetime = time.Hour
+
+var ErrInvalidContinuationToken = errors.New("continuation token is malformed or its signature does not match")
+var ErrExpiredContinuationToken = errors.New("continuation token has expired")
+
+// Continuation tokens can only be resumed by the client that started the inspection. See Options.Client
+var ErrContinuationOtherClient = errors.New("continuation token belongs to another client")
+
+// Resumed inspections only analyse links, which needs Options.LinkAnalyticsDeadline
+var ErrContinuationWithoutDeadline = errors.New("resuming an inspection needs a link analytics deadline")
+
+// Everything needed to resume an inspection on any instance.
+// Only the unfinished links are kept. Everything else was already returned by the previous requests
+type continuationState struct {
+	IssuedAt int64 `json:"iat"`
+
+	// Options.Client of the inspection
+	Client string `json:"client,omitempty"`
+
+	URL         string `json:"url"`
+	StatusCode  int    `json:"status"`
+	StatusMsg   string `json:"msg,omitempty"`
+	InsecureTLS bool   `json:"insecure,omitempty"`
+
+	// Link counts of the page, which the resumed report has to carry on
+	TotalLinks    int `json:"total"`
+	ExternalLinks int `json:"external"`
+	InternalLinks int `json:"internal"`
+
+	// Links the previous requests did not finish, and the results of the ones they did
+	Links    []*continuationLink `json:"links"`
+	Previous *linkResults        `json:"previous"`
+
+	// Sample of Options.SampleSize, if the links are sampled
+	SampleSize       int            `json:"sample_size,omitempty"`
+	StrataPopulation map[string]int `json:"strata,omitempty"`
+}
+
+// continuationLink is an unfinished link, with what is needed to analyse it
+type continuationLink struct {
+	URL     string `json:"u"`
+	Type    string `json:"t"`
+	Section string `json:"s,omitempty"`
+	Stratum string `json:"st,omitempty"`
+}
+
+// linkResults counts the analysed links of a report, and the ones that will not be analysed.
+// Unfinished links are not included
+type linkResults struct {
+	Accessible   int `json:"a,omitempty"`
+	Inaccessible int `json:"i,omitempty"`
+	NotAnalysed  int `json:"n,omitempty"`
+
+	// Checked and broken links of the sample, by stratum
+	SampleChecked map[string]int `json:"sc,omitempty"`
+	SampleBroken  map[string]int `json:"sb,omitempty"`
+}
+
+// finishedLinkResults counts the finished links of the report, including the ones of the requests before it
+func (report *InspectReport) finishedLinkResults() *linkResults {
+	results := &linkResults{SampleChecked: map[string]int{}, SampleBroken: map[string]int{}}
+
+	if report.previousLinks != nil {
+		results.Accessible = report.previousLinks.Accessible
+		results.Inaccessible = report.previousLinks.Inaccessible
+		results.NotAnalysed = report.previousLinks.NotAnalysed
+
+		for key, count := range report.previousLinks.SampleChecked {
+			results.SampleChecked[key] += count
+		}
+		for key, count := range report.previousLinks.SampleBroken {
+			results.SampleBroken[key] += count
+		}
+	}
+
+	defer report.readLockLinks()()
+
+	for _, lnk := range report.Links {
+		switch {
+		case lnk.Unfinished:
+		case lnk.StatusCode == 0:
+			if isAnalysableLinkType(lnk.Type) {
+				results.NotAnalysed++
+			}
+		case lnk.StatusCode < 400:
+			results.Accessible++
+		default:
+			results.Inaccessible++
+		}
+
+		if len(lnk.Stratum) > 0 && lnk.StatusCode != 0 {
+			results.SampleChecked[lnk.Stratum]++
+			if lnk.StatusCode >= 400 {
+				results.SampleBroken[lnk.Stratum]++
+			}
+		}
+	}
+
+	return results
+}
+
+// ContinuationToken encodes the unfinished links of the report and the results of the others into a compact signed token.
+// The unfinished links are analysed when the token is passed to ResumeInspection.
+//
+// Token format: base64url(deflate(json state)) + "." + base64url(HMAC-SHA256 of the first part)
+func (report *InspectReport) ContinuationToken(secret []byte) (string, error) {
+
+	state := continuationState{
+		IssuedAt: time.Now().Unix(),
+		Client:   report.Options.Client,
+
+		URL:         report.URL,
+		StatusCode:  report.StatusCode,
+		StatusMsg:   report.StatusMsg,
+		InsecureTLS: report.InsecureTLS,
+
+		TotalLinks:    report.TotalLinkCount,
+		ExternalLinks: report.ExternalLinkCount,
+		InternalLinks: report.InternalLinkCount,
+
+		Links:    []*continuationLink{},
+		Previous: report.finishedLinkResults(),
+	}
+
+	unlockLinks := report.readLockLinks()
+	for _, lnk := range report.Links {
+		if lnk.Unfinished {
+			state.Links = append(state.Links, &continuationLink{URL: lnk.URL, Type: lnk.Type, Section: lnk.Section, Stratum: lnk.Stratum})
+		}
+	}
+	unlockLinks()
+
+	if report.LinkSample != nil {
+		state.SampleSize = report.LinkSample.SampleSize
+		state.StrataPopulation = report.LinkSample.StrataPopulation
+	}
+
+	stateJSON, stateJSONErr := json.Marshal(state)
+	if stateJSONErr != nil {
+		return "", stateJSONErr
+	}
+
+	var compressed bytes.Buffer
+	compressor, compressorErr := flate.NewWriter(&compressed, flate.BestCompression)
+	if compressorErr != nil {
+		return "", compressorErr
+	}
+
+	compressor.Write(stateJSON)
+	if closeErr := compressor.Close(); closeErr != nil {
+		return "", closeErr
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(compressed.Bytes())
+	signature := base64.RawURLEncoding.EncodeToString(signContinuation(payload, secret))
+
+	return payload + "." + signature, nil
+}
+
+// ResumeInspection continues analysing the unfinished links of a continuation token in the background.
+//
+// The report only has the resumed links. Its link counts and sample estimates also include the links
+// finished by the previous requests, which returned everything else about the page.
+//
+// Options are not part of the token. Pass the options for the resumed link analysis in opts.
+// They must have a LinkAnalyticsDeadline, otherwise ErrContinuationWithoutDeadline is returned,
+// and the Client that started the inspection, otherwise ErrContinuationOtherClient is returned.
+func ResumeInspection(token string, secret []byte, opts *Options) (*InspectReport, error) {
+
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	tokenParts := strings.Split(token, ".")
+	if len(tokenParts) != 2 {
+		return nil, ErrInvalidContinuationToken
+	}
+
+	payload := tokenParts[0]
+	signature, signatureErr := base64.RawURLEncoding.DecodeString(tokenParts[1])
+
+	// Verify the signature before touching the payload
+	if signatureErr != nil || !hmac.Equal(signature, signContinuation(payload, secret)) {
+		return nil, ErrInvalidContinuationToken
+	}
+
+	compressed, compressedErr := base64.RawURLEncoding.DecodeString(payload)
+	if compressedErr != nil {
+		return nil, ErrInvalidContinuationToken
+	}
+
+	stateJSON, stateJSONErr := io.ReadAll(flate.NewReader(bytes.NewReader(compressed)))
+	if stateJSONErr != nil {
+		return nil, ErrInvalidContinuationToken
+	}
+
+	var state continuationState
+	if unmarshalErr := json.Unmarshal(stateJSON, &state); unmarshalErr != nil || state.Previous == nil {
+		return nil, ErrInvalidContinuationToken
+	}
+
+	if time.Since(time.Unix(state.IssuedAt, 0)) > ContinuationTokenLifetime {
+		return nil, ErrExpiredContinuationToken
+	}
+
+	if state.Client != opts.Client {
+		return nil, ErrContinuationOtherClient
+	}
+
+	// Without a deadline the links would not be analysed, and their results would be lost
+	if opts.LinkAnalyticsDeadline == nil {
+		return nil, ErrContinuationWithoutDeadline
+	}
+
+	parsedURL, parsedURLErr := url.Parse(state.URL)
+	if parsedURLErr != nil {
+		return nil, ErrInvalidContinuationToken
+	}
+
+	report := newInspectReport(state.URL, opts)
+	report.ParsedURL = parsedURL
+	report.HTTPClient = opts.newLinkHTTPClient(parsedURL)
+
+	report.StatusCode = state.StatusCode
+	report.StatusMsg = state.StatusMsg
+	report.InsecureTLS = report.InsecureTLS || state.InsecureTLS
+
+	report.TotalLinkCount = state.TotalLinks
+	report.ExternalLinkCount = state.ExternalLinks
+	report.InternalLinkCount = state.InternalLinks
+	report.previousLinks = state.Previous
+
+	if state.SampleSize > 0 {
+		report.LinkSample = &LinkSample{SampleSize: state.SampleSize, StrataPopulation: state.StrataPopulation}
+	}
+
+	// Analyse the links the previous request did not finish
+	for _, continued := range state.Links {
+		lnk := &InspectedLink{URL: continued.URL, Type: continued.Type, Section: continued.Section, Stratum: continued.Stratum}
+		report.Links = append(report.Links, lnk)
+		report.scheduleLinkAnalysis(lnk)
+	}
+	report.startLinkAnalysis()
+
+	return report, nil
+}
+
+func signContinuation(payload string, secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
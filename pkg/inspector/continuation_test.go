@@ -0,0 +1,105 @@
+package inspector
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestContinuationTokenResume(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/missing" {
+			http.NotFound(wr, req)
+			return
+		}
+		wr.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	page := `<html><head><title>Resumable</title></head><body><h1>Heading</h1><a href="/found">found</a><a href="/missing">missing</a></body></html>`
+
+	// A deadline in the past leaves every link unfinished
+	pastDeadline := time.Now()
+	report := inspectURLResponse(server.URL, &http.Response{StatusCode: 200, Status: "200 OK", Body: io.NopCloser(strings.NewReader(page))}, nil, &Options{LinkAnalyticsDeadline: &pastDeadline, AllowedNetworks: loopbackNetworks, Client: "ci"})
+	report.FinishLinkAnalysis()
+	report.CountLinks()
+
+	if report.UnfinishedLinkCount != 2 {
+		t.Fatalf("returned %d unfinished links, expected 2", report.UnfinishedLinkCount)
+	}
+
+	// Finish one link, as if the first request checked it
+	report.Links[0].StatusCode = http.StatusOK
+	report.Links[0].Unfinished = false
+	report.CountLinks()
+
+	secret := []byte("test secret")
+	token, tokenErr := report.ContinuationToken(secret)
+	if tokenErr != nil {
+		t.Fatal(tokenErr)
+	}
+
+	if _, err := ResumeInspection(token, []byte("another secret"), nil); err != ErrInvalidContinuationToken {
+		t.Errorf("resuming with a wrong secret returned %v, expected %v", err, ErrInvalidContinuationToken)
+	}
+
+	if _, err := ResumeInspection(strings.Replace(token, ".", "x.", 1), secret, nil); err != ErrInvalidContinuationToken {
+		t.Errorf("resuming a tampered token returned %v, expected %v", err, ErrInvalidContinuationToken)
+	}
+
+	otherClientDeadline := time.Now().Add(5 * time.Second)
+	if _, err := ResumeInspection(token, secret, &Options{LinkAnalyticsDeadline: &otherClientDeadline, Client: "other key"}); err != ErrContinuationOtherClient {
+		t.Errorf("resuming as another client returned %v, expected %v", err, ErrContinuationOtherClient)
+	}
+
+	if _, err := ResumeInspection(token, secret, &Options{AllowedNetworks: loopbackNetworks, Client: "ci"}); err != ErrContinuationWithoutDeadline {
+		t.Errorf("resuming without a deadline returned %v, expected %v", err, ErrContinuationWithoutDeadline)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	resumed, resumeErr := ResumeInspection(token, secret, &Options{LinkAnalyticsDeadline: &deadline, AllowedNetworks: loopbackNetworks, Client: "ci"})
+	if resumeErr != nil {
+		t.Fatal(resumeErr)
+	}
+
+	resumed.LinkAnalyticWG.Wait()
+	resumed.FinishLinkAnalysis()
+	resumed.CountLinks()
+
+	// Only the unfinished link is resumed, and the finished one is still counted
+	if len(resumed.Links) != 1 || !strings.HasSuffix(resumed.Links[0].URL, "/missing") || resumed.TotalLinkCount != 2 {
+		t.Errorf("resumed report returned links %v of %d, expected only /missing of 2", resumed.Links, resumed.TotalLinkCount)
+	}
+	if resumed.AccessibleLinkCount != 1 || resumed.InaccessibleLinkCount != 1 || resumed.UnfinishedLinkCount != 0 {
+		t.Errorf("resumed report returned %d accessible, %d inaccessible and %d unfinished links, expected 1, 1 and 0",
+			resumed.AccessibleLinkCount, resumed.InaccessibleLinkCount, resumed.UnfinishedLinkCount)
+	}
+}
+
+func TestContinuationTokenIsCompact(t *testing.T) {
+
+	// A large page with a single unfinished link
+	var page strings.Builder
+	for i := 0; i < 500; i++ {
+		page.WriteString(`<h2>Heading ` + strconv.Itoa(i) + `</h2><p>Some text</p>`)
+	}
+	page.WriteString(`<a href="/unfinished">unfinished</a>`)
+
+	pastDeadline := time.Now()
+	report := inspectURLResponse("https://example.com", &http.Response{StatusCode: 200, Status: "200 OK", Body: io.NopCloser(strings.NewReader(page.String()))}, nil, &Options{LinkAnalyticsDeadline: &pastDeadline})
+	report.FinishLinkAnalysis()
+
+	token, tokenErr := report.ContinuationToken([]byte("test secret"))
+	if tokenErr != nil {
+		t.Fatal(tokenErr)
+	}
+
+	if len(token) > 300 {
+		t.Errorf("returned a token of %d bytes for one unfinished link, expected it not to carry the rest of the report", len(token))
+	}
+}
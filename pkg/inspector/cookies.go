@@ -0,0 +1,160 @@
+package inspector
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Issues of cookies. See InspectedCookie.Issues
+const (
+	// A session-like cookie is sent over plain HTTP
+	CookieIssueMissingSecure = "missing_secure"
+
+	// A session-like cookie can be read by scripts
+	CookieIssueMissingHttpOnly = "missing_httponly"
+
+	// A session-like cookie relies on the browser's default SameSite policy
+	CookieIssueMissingSameSite = "missing_samesite"
+
+	// Browsers reject SameSite=None cookies without Secure
+	CookieIssueSameSiteNoneInsecure = "samesite_none_insecure"
+
+	// Browsers reject cookies larger than MaxCookieSize
+	CookieIssueOversized = "oversized"
+)
+
+// Maximum size of the name and value of a cookie accepted by browsers
+const MaxCookieSize = 4096
+
+// Words of the names of cookies that hold sessions or credentials, such as laravel_session or connect.sid. See hasNameWords
+var sessionCookieNames = []string{"session", "sess", "sessid", "sessionid", "sid", "auth", "token", "jwt", "remember", "login", "logged", "user-id"}
+
+// Names of session cookies of web frameworks that are not made of separate words
+var sessionCookieExactNames = []string{"phpsessid", "jsessionid", ".aspxauth", "aspxauth"}
+
+// InspectedCookie is a cookie set by the page response, or by a redirect to it
+type InspectedCookie struct {
+	Name string `json:"name"`
+
+	// Domain attribute. Empty if the cookie is only sent to the host that set it
+	Domain string `json:"domain,omitempty"`
+	Path   string `json:"path,omitempty"`
+
+	Secure   bool `json:"secure"`
+	HttpOnly bool `json:"http_only"`
+
+	// strict, lax or none. Empty if not set, which browsers treat as lax
+	SameSite string `json:"same_site,omitempty"`
+
+	// Expiry time in RFC 3339 format, from Expires or Max-Age. Empty for session cookies, which expire with the browser session
+	Expires string `json:"expires,omitempty"`
+
+	// Set if the cookie is expired, which deletes it from the browser
+	Deleted bool `json:"deleted,omitempty"`
+
+	// Length of the name and value in bytes
+	Size int `json:"size"`
+
+	// URL of the response that set the cookie
+	SetBy string `json:"set_by"`
+
+	// True if the name looks like a session or credential cookie
+	SessionLike bool `json:"session_like"`
+
+	// Missing protective attributes and other issues. See the CookieIssue constants
+	Issues []Problem `json:"issues"`
+}
+
+// auditCookies lists the cookies set by the response and the redirects before it, in the order they were set
+func (report *InspectReport) auditCookies(httpResp *http.Response) []*InspectedCookie {
+	responses := []*http.Response{}
+	for resp := httpResp; resp != nil; {
+		responses = append([]*http.Response{resp}, responses...)
+
+		if resp.Request == nil {
+			break
+		}
+		resp = resp.Request.Response
+	}
+
+	cookies := []*InspectedCookie{}
+	now := time.Now()
+
+	for _, resp := range responses {
+		setBy := report.URL
+		if resp.Request != nil && resp.Request.URL != nil {
+			setBy = resp.Request.URL.String()
+		}
+
+		for _, cookie := range resp.Cookies() {
+			cookies = append(cookies, inspectCookie(cookie, setBy, now))
+		}
+	}
+
+	return cookies
+}
+
+func inspectCookie(cookie *http.Cookie, setBy string, now time.Time) *InspectedCookie {
+	inspectedCookie := &InspectedCookie{
+		Name:     cookie.Name,
+		Domain:   cookie.Domain,
+		Path:     cookie.Path,
+		Secure:   cookie.Secure,
+		HttpOnly: cookie.HttpOnly,
+		Size:     len(cookie.Name) + len(cookie.Value),
+		SetBy:    setBy,
+		Issues:   []Problem{},
+	}
+
+	switch cookie.SameSite {
+	case http.SameSiteStrictMode:
+		inspectedCookie.SameSite = "strict"
+	case http.SameSiteLaxMode:
+		inspectedCookie.SameSite = "lax"
+	case http.SameSiteNoneMode:
+		inspectedCookie.SameSite = "none"
+	}
+
+	// Max-Age takes precedence over Expires
+	if cookie.MaxAge > 0 {
+		inspectedCookie.Expires = now.Add(time.Duration(cookie.MaxAge) * time.Second).UTC().Format(time.RFC3339)
+	} else if cookie.MaxAge < 0 {
+		inspectedCookie.Deleted = true
+	} else if !cookie.Expires.IsZero() {
+		inspectedCookie.Expires = cookie.Expires.UTC().Format(time.RFC3339)
+		inspectedCookie.Deleted = cookie.Expires.Before(now)
+	}
+
+	inspectedCookie.SessionLike = containsString(sessionCookieExactNames, strings.ToLower(cookie.Name))
+	for _, sessionCookieName := range sessionCookieNames {
+		if hasNameWords(cookie.Name, sessionCookieName) {
+			inspectedCookie.SessionLike = true
+			break
+		}
+	}
+
+	if inspectedCookie.SameSite == "none" && !cookie.Secure {
+		inspectedCookie.Issues = append(inspectedCookie.Issues, Problem{CookieIssueSameSiteNoneInsecure, "SameSite=None without Secure is rejected by browsers"})
+	}
+	if inspectedCookie.Size > MaxCookieSize {
+		inspectedCookie.Issues = append(inspectedCookie.Issues, Problem{CookieIssueOversized, "The cookie is larger than 4096 bytes and is rejected by browsers"})
+	}
+
+	// A cookie being deleted can not leak
+	if !inspectedCookie.SessionLike || inspectedCookie.Deleted {
+		return inspectedCookie
+	}
+
+	if !cookie.Secure {
+		inspectedCookie.Issues = append(inspectedCookie.Issues, Problem{CookieIssueMissingSecure, "The session cookie is also sent over plain HTTP. Set Secure"})
+	}
+	if !cookie.HttpOnly {
+		inspectedCookie.Issues = append(inspectedCookie.Issues, Problem{CookieIssueMissingHttpOnly, "The session cookie can be read by scripts. Set HttpOnly"})
+	}
+	if len(inspectedCookie.SameSite) == 0 {
+		inspectedCookie.Issues = append(inspectedCookie.Issues, Problem{CookieIssueMissingSameSite, "The session cookie relies on the browser's default SameSite policy. Set SameSite=Lax or Strict"})
+	}
+
+	return inspectedCookie
+}
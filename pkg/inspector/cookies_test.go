@@ -0,0 +1,101 @@
+package inspector
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditCookies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/start" {
+			http.SetCookie(wr, &http.Cookie{Name: "tracking", Value: "1", Domain: "127.0.0.1", MaxAge: 3600})
+			http.Redirect(wr, req, "/page", http.StatusFound)
+			return
+		}
+
+		http.SetCookie(wr, &http.Cookie{Name: "session_id", Value: "abc", Path: "/"})
+		http.SetCookie(wr, &http.Cookie{Name: "auth", Value: "abc", Secure: true, HttpOnly: true, SameSite: http.SameSiteStrictMode})
+		http.SetCookie(wr, &http.Cookie{Name: "old_session", Value: "", MaxAge: -1})
+		http.SetCookie(wr, &http.Cookie{Name: "embed", Value: "1", SameSite: http.SameSiteNoneMode})
+		http.SetCookie(wr, &http.Cookie{Name: "big", Value: strings.Repeat("a", 5000)})
+		wr.Write([]byte("<title>Page</title>"))
+	}))
+	defer server.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	report := InspectURLWithOptions(server.URL+"/start", &Options{LinkAnalyticsDeadline: &deadline, AllowedNetworks: loopbackNetworks})
+
+	expected := []struct {
+		name   string
+		setBy  string
+		issues []string
+	}{
+		{"tracking", "/start", []string{}},
+		{"session_id", "/page", []string{CookieIssueMissingSecure, CookieIssueMissingHttpOnly, CookieIssueMissingSameSite}},
+		{"auth", "/page", []string{}},
+		{"old_session", "/page", []string{}},
+		{"embed", "/page", []string{CookieIssueSameSiteNoneInsecure}},
+		{"big", "/page", []string{CookieIssueOversized}},
+	}
+
+	if len(report.Cookies) != len(expected) {
+		t.Fatalf("returned %d cookies, expected %d", len(report.Cookies), len(expected))
+	}
+
+	for i, cookie := range report.Cookies {
+		if cookie.Name != expected[i].name || cookie.SetBy != server.URL+expected[i].setBy {
+			t.Errorf("returned cookie %s set by %s, expected %s set by %s", cookie.Name, cookie.SetBy, expected[i].name, expected[i].setBy)
+		}
+
+		if len(cookie.Issues) != len(expected[i].issues) {
+			t.Errorf("cookie %s has issues %+v, expected %v", cookie.Name, cookie.Issues, expected[i].issues)
+			continue
+		}
+		for j, issue := range cookie.Issues {
+			if issue.Code != expected[i].issues[j] {
+				t.Errorf("cookie %s has issue %s, expected %s", cookie.Name, issue.Code, expected[i].issues[j])
+			}
+		}
+	}
+
+	if tracking := report.Cookies[0]; tracking.Domain != "127.0.0.1" || len(tracking.Expires) == 0 {
+		t.Errorf("tracking cookie was parsed as %+v", tracking)
+	}
+	if auth := report.Cookies[2]; !auth.Secure || !auth.HttpOnly || auth.SameSite != "strict" || !auth.SessionLike {
+		t.Errorf("auth cookie was parsed as %+v", auth)
+	}
+	if !report.Cookies[3].Deleted {
+		t.Errorf("old_session cookie was not marked deleted")
+	}
+}
+
+func TestSessionLikeCookieNames(t *testing.T) {
+	expected := map[string]bool{
+		"PHPSESSID":                        true,
+		"JSESSIONID":                       true,
+		"ASP.NET_SessionId":                true,
+		"connect.sid":                      true,
+		"laravel_session":                  true,
+		"__Secure-next-auth.session-token": true,
+		"authToken":                        true,
+		"remember_web_59ba36addc2b2f94":    true,
+		"user_id":                          true,
+
+		// Names that only contain the letters of a session word
+		"author":          false,
+		"inside":          false,
+		"consider":        false,
+		"tokenizer_theme": false,
+		"classname":       false,
+		"theme":           false,
+	}
+
+	for name, sessionLike := range expected {
+		if cookie := inspectCookie(&http.Cookie{Name: name, Value: "1"}, "https://example.com", time.Now()); cookie.SessionLike != sessionLike {
+			t.Errorf("cookie %s was session like %t, expected %t", name, cookie.SessionLike, sessionLike)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package inspector
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// Only the web page gets the credentials
+	CredentialPolicyPage = "page"
+
+	// Link checks to the host of the web page get the credentials too, so pages behind the same login can be checked
+	CredentialPolicySameHost = "same-host"
+)
+
+var CredentialPolicies = []string{CredentialPolicyPage, CredentialPolicySameHost}
+
+// BasicAuth is a username and password for HTTP basic authentication
+type BasicAuth struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+func (opts *Options) hasCredentials() bool {
+	return len(opts.Cookies) > 0 || len(opts.Headers) > 0 || opts.BasicAuth != nil || opts.jar != nil
+}
+
+func (opts *Options) validateCredentials() *InspectError {
+	if len(opts.CredentialPolicy) > 0 && !containsString(CredentialPolicies, opts.CredentialPolicy) {
+		return NewInspectError(ErrorCodeInvalidRequest, "Unknown credential policy "+opts.CredentialPolicy+". Expected one of "+strings.Join(CredentialPolicies, ", "), false, nil)
+	}
+
+	for headerName, headerValues := range opts.Headers {
+		if len(headerName) == 0 || strings.ContainsAny(headerName, " :\r\n") {
+			return NewInspectError(ErrorCodeInvalidRequest, "Invalid header name "+headerName, false, nil)
+		}
+
+		if strings.EqualFold(headerName, "Host") {
+			return NewInspectError(ErrorCodeInvalidRequest, "The Host header can not be set", false, nil)
+		}
+
+		for _, headerValue := range headerValues {
+			if strings.ContainsAny(headerValue, "\r\n") {
+				return NewInspectError(ErrorCodeInvalidRequest, "Invalid value of header "+headerName, false, nil)
+			}
+		}
+	}
+
+	if opts.Login != nil {
+		if _, urlErr := NormalizeURL(opts.Login.URL); urlErr != nil {
+			return NewInspectError(ErrorCodeInvalidRequest, "Invalid login URL : "+urlErr.Message, false, nil)
+		}
+
+		if len(opts.Login.Fields) == 0 {
+			return NewInspectError(ErrorCodeInvalidRequest, "Login needs the values of the form fields, such as the username and password", false, nil)
+		}
+	}
+
+	for _, cookie := range opts.Cookies {
+		if len(cookie.Name) == 0 || strings.ContainsAny(cookie.Name, " =;\r\n") || strings.ContainsAny(cookie.Value, ";\r\n") {
+			return NewInspectError(ErrorCodeInvalidRequest, "Invalid cookie "+cookie.Name, false, nil)
+		}
+	}
+
+	return nil
+}
+
+// addCredentials adds the cookies, headers and basic auth of the options to the request
+func (opts *Options) addCredentials(req *http.Request) {
+	for headerName, headerValues := range opts.Headers {
+		req.Header.Del(headerName)
+		for _, headerValue := range headerValues {
+			req.Header.Add(headerName, headerValue)
+		}
+	}
+
+	for _, cookie := range opts.Cookies {
+		req.AddCookie(cookie)
+	}
+
+	if opts.BasicAuth != nil {
+		req.SetBasicAuth(opts.BasicAuth.Username, opts.BasicAuth.Password)
+	}
+}
+
+// sendsCredentialsTo reports whether the link check of linkURL gets the credentials of the web page at pageURL
+func (opts *Options) sendsCredentialsTo(pageURL *url.URL, linkURL string) bool {
+	if opts.CredentialPolicy != CredentialPolicySameHost || !opts.hasCredentials() || pageURL == nil {
+		return false
+	}
+
+	parsedLinkURL, parseErr := url.Parse(linkURL)
+	if parseErr != nil {
+		return false
+	}
+
+	return isSameOrigin(pageURL, parsedLinkURL)
+}
+
+// linkCookieJar returns the cookie jar of link checks. It holds the session of Login only for links allowed by CredentialPolicy
+func (opts *Options) linkCookieJar(pageURL *url.URL) http.CookieJar {
+	if opts.jar == nil || opts.CredentialPolicy != CredentialPolicySameHost || pageURL == nil {
+		return nil
+	}
+	return &sameOriginJar{jar: opts.jar, origin: pageURL}
+}
+
+// sameOriginJar is a cookie jar that only works for URLs of the same origin
+type sameOriginJar struct {
+	jar    http.CookieJar
+	origin *url.URL
+}
+
+func (jar *sameOriginJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	if isSameOrigin(jar.origin, u) {
+		jar.jar.SetCookies(u, cookies)
+	}
+}
+
+func (jar *sameOriginJar) Cookies(u *url.URL) []*http.Cookie {
+	if isSameOrigin(jar.origin, u) {
+		return jar.jar.Cookies(u)
+	}
+	return nil
+}
+
+// checkCredentialRedirect follows redirects like the default client, but removes the credentials
+// when a redirect leaves the host they were sent to. The default client would still forward custom headers.
+func (opts *Options) checkCredentialRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+
+	if !isSameOrigin(via[0].URL, req.URL) {
+		req.Header.Del("Authorization")
+		req.Header.Del("Cookie")
+		for headerName := range opts.Headers {
+			req.Header.Del(headerName)
+		}
+	}
+
+	return nil
+}
+
+// isSameOrigin reports whether both URLs have the same scheme, host and port.
+// A credential sent over https is not sent to the same host over plain http
+func isSameOrigin(a *url.URL, b *url.URL) bool {
+	return strings.EqualFold(a.Scheme, b.Scheme) && strings.EqualFold(a.Host, b.Host)
+}
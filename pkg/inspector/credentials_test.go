@@ -0,0 +1,101 @@
+package inspector
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCredentialPolicy(t *testing.T) {
+
+	// Records any credential that reaches a third party website
+	leakedCredentials := []string{}
+	leakedCredentialsLock := sync.Mutex{}
+
+	thirdParty := httptest.NewServer(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		leakedCredentialsLock.Lock()
+		defer leakedCredentialsLock.Unlock()
+
+		for _, headerName := range []string{"Authorization", "Cookie", "X-Token"} {
+			if len(req.Header.Get(headerName)) > 0 {
+				leakedCredentials = append(leakedCredentials, req.URL.Path+" "+headerName)
+			}
+		}
+	}))
+	defer thirdParty.Close()
+
+	page := `<a href="/private">private</a><a href="/redirect">redirect</a><a href="` + thirdParty.URL + `/external">external</a>`
+
+	server := httptest.NewServer(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		username, password, hasBasicAuth := req.BasicAuth()
+		session, _ := req.Cookie("session")
+
+		if !hasBasicAuth || username != "staging" || password != "secret" || session == nil || req.Header.Get("X-Token") != "token" {
+			wr.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch req.URL.Path {
+		case "/redirect":
+			http.Redirect(wr, req, thirdParty.URL+"/redirected", http.StatusFound)
+		case "/private":
+			wr.Write([]byte("private"))
+		default:
+			wr.Write([]byte(page))
+		}
+	}))
+	defer server.Close()
+
+	inspect := func(credentialPolicy string) *InspectReport {
+		deadline := time.Now().Add(5 * time.Second)
+		report := InspectURLWithOptions(server.URL, &Options{
+			LinkAnalyticsDeadline: &deadline,
+			AllowedNetworks:       loopbackNetworks,
+			Cookies:               []*http.Cookie{{Name: "session", Value: "abc"}},
+			Headers:               http.Header{"X-Token": []string{"token"}},
+			BasicAuth:             &BasicAuth{Username: "staging", Password: "secret"},
+			CredentialPolicy:      credentialPolicy,
+		})
+		report.LinkAnalyticWG.Wait()
+		report.CountLinks()
+		return report
+	}
+
+	linkStatus := func(report *InspectReport, path string) int {
+		for _, lnk := range report.Links {
+			if lnk.URL == server.URL+path {
+				return lnk.StatusCode
+			}
+		}
+		return 0
+	}
+
+	report := inspect(CredentialPolicyPage)
+	if report.StatusCode != http.StatusOK || len(report.Links) != 3 {
+		t.Fatalf("authenticated page returned status %d and %d links", report.StatusCode, len(report.Links))
+	}
+	if status := linkStatus(report, "/private"); status != http.StatusUnauthorized {
+		t.Errorf("private link returned status %d without credentials, expected 401", status)
+	}
+
+	report = inspect(CredentialPolicySameHost)
+	if status := linkStatus(report, "/private"); status != http.StatusOK {
+		t.Errorf("private link returned status %d with the same host policy, expected 200", status)
+	}
+	if status := linkStatus(report, "/redirect"); status != http.StatusOK {
+		t.Errorf("redirect link returned status %d, expected 200", status)
+	}
+
+	if len(leakedCredentials) > 0 {
+		t.Errorf("credentials were sent to a third party : %v", leakedCredentials)
+	}
+
+	if validationErr := (&Options{CredentialPolicy: "everywhere"}).Validate(); validationErr == nil {
+		t.Errorf("unknown credential policy was accepted")
+	}
+	if validationErr := (&Options{Headers: http.Header{"X-Token": []string{"a\r\nHost: evil"}}}).Validate(); validationErr == nil {
+		t.Errorf("header with a line break was accepted")
+	}
+}
@@ -0,0 +1,129 @@
+package inspector
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// Stable error codes. API consumers should match these instead of error messages
+const (
+	ErrorCodeInvalidRequest      = "invalid_request"
+	ErrorCodeMethodNotAllowed    = "method_not_allowed"
+	ErrorCodeRateLimited         = "rate_limited"
+	ErrorCodeUnauthorized        = "unauthorized"
+	ErrorCodeForbidden           = "forbidden"
+	ErrorCodeInvalidURL          = "invalid_url"
+	ErrorCodeInvalidContinuation = "invalid_continuation"
+	ErrorCodeDNSFailure          = "dns_failure"
+	ErrorCodeUnreachable         = "unreachable"
+	ErrorCodeBlockedAddress      = "blocked_address"
+	ErrorCodeTimeout             = "timeout"
+	ErrorCodeTLSFailure          = "tls_failure"
+	ErrorCodeFetchFailed         = "fetch_failed"
+	ErrorCodeLoginFailed         = "login_failed"
+	ErrorCodeInternal            = "internal_error"
+)
+
+// InspectError describes why an inspection or an API request failed
+type InspectError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+
+	// Additional context, such as the host that failed to resolve. The underlying Go error is in Details["cause"]
+	Details map[string]string `json:"details,omitempty"`
+
+	// True if the same request might succeed later
+	Retryable bool `json:"retryable"`
+}
+
+func (inspectErr *InspectError) Error() string {
+	return inspectErr.Code + ": " + inspectErr.Message
+}
+
+// NewInspectError creates an InspectError. cause is optional and only recorded in the details
+func NewInspectError(code string, message string, retryable bool, cause error) *InspectError {
+	inspectErr := &InspectError{
+		Code:      code,
+		Message:   message,
+		Retryable: retryable,
+	}
+
+	if cause != nil {
+		inspectErr.Details = map[string]string{"cause": cause.Error()}
+	}
+
+	return inspectErr
+}
+
+// WriteError responds with the JSON error schema of the API: {"error": {"code", "message", "details", "retryable"}}
+func WriteError(wr http.ResponseWriter, statusCode int, inspectErr *InspectError) {
+	wr.Header().Set("Content-Type", "application/json")
+	wr.WriteHeader(statusCode)
+	json.NewEncoder(wr).Encode(map[string]*InspectError{"error": inspectErr})
+}
+
+// ClassifyFetchError converts an error returned by the HTTP client into an InspectError
+func ClassifyFetchError(err error) *InspectError {
+
+	var inspectErr *InspectError
+	if errors.As(err, &inspectErr) {
+		return inspectErr
+	}
+
+	var blockedErr *BlockedAddressError
+	if errors.As(err, &blockedErr) {
+		classified := NewInspectError(ErrorCodeBlockedAddress, "The web page is on a private or reserved network, which is not allowed", false, err)
+		classified.Details["ip"] = blockedErr.IP.String()
+		return classified
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		classified := NewInspectError(ErrorCodeDNSFailure, "The host name could not be resolved", dnsErr.IsTemporary || dnsErr.IsTimeout, err)
+		classified.Details["host"] = dnsErr.Name
+		return classified
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return NewInspectError(ErrorCodeTimeout, "The web page did not respond in time", true, err)
+	}
+
+	if isTLSError(err) {
+		return NewInspectError(ErrorCodeTLSFailure, "A secure connection to the web page could not be established", false, err)
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return NewInspectError(ErrorCodeUnreachable, "The web server could not be reached", true, err)
+	}
+
+	// The URL of the request that failed, which is the redirect target if a redirect failed
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		if failedURL, parseErr := url.Parse(urlErr.URL); parseErr == nil && len(failedURL.Scheme) > 0 && failedURL.Scheme != "http" && failedURL.Scheme != "https" {
+			return NewInspectError(ErrorCodeInvalidURL, "The URL scheme is not supported", false, err)
+		}
+	}
+
+	return NewInspectError(ErrorCodeFetchFailed, "The web page could not be fetched", true, err)
+}
+
+// isTLSError reports whether the TLS handshake failed, such as on an untrusted certificate or a handshake alert of the server
+func isTLSError(err error) bool {
+	var certificateVerificationErr *tls.CertificateVerificationError
+	var recordHeaderErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var certificateInvalidErr x509.CertificateInvalidError
+
+	return errors.As(err, &certificateVerificationErr) || errors.As(err, &recordHeaderErr) || errors.As(err, &alertErr) ||
+		errors.As(err, &unknownAuthorityErr) || errors.As(err, &hostnameErr) || errors.As(err, &certificateInvalidErr)
+}
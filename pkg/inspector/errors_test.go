@@ -0,0 +1,55 @@
+package inspector
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestClassifyFetchError(t *testing.T) {
+
+	_, refusedErr := http.Get("http://127.0.0.1:1")
+
+	ftpServer := httptest.NewServer(http.RedirectHandler("ftp://example.com/file", http.StatusFound))
+	defer ftpServer.Close()
+	_, ftpRedirectErr := http.Get(ftpServer.URL)
+
+	expectedCodes := map[error]string{
+		&url.Error{Op: "Get", URL: "https://nosuchhost", Err: &net.DNSError{Name: "nosuchhost", IsNotFound: true}}: ErrorCodeDNSFailure,
+		&url.Error{Op: "Get", URL: "https://go.dev", Err: context.DeadlineExceeded}:                                ErrorCodeTimeout,
+		refusedErr:                   ErrorCodeUnreachable,
+		errors.New("something else"): ErrorCodeFetchFailed,
+
+		// The scheme of the URL that failed identifies unsupported schemes, including redirects to them
+		ftpRedirectErr: ErrorCodeInvalidURL,
+		&url.Error{Op: "Get", URL: "https://go.dev", Err: errors.New("unsupported protocol scheme")}: ErrorCodeFetchFailed,
+
+		&url.Error{Op: "Get", URL: "https://go.dev", Err: &tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}}:          ErrorCodeTLSFailure,
+		&url.Error{Op: "Get", URL: "https://go.dev", Err: tls.RecordHeaderError{Msg: "first record does not look like a TLS handshake"}}: ErrorCodeTLSFailure,
+		&url.Error{Op: "Get", URL: "https://go.dev", Err: x509.HostnameError{Host: "go.dev"}}:                                            ErrorCodeTLSFailure,
+
+		// Only the error types identify TLS failures, not the message
+		errors.New("tls: looks like a TLS error"): ErrorCodeFetchFailed,
+	}
+
+	for err, expectedCode := range expectedCodes {
+		inspectErr := ClassifyFetchError(err)
+		if inspectErr.Code != expectedCode {
+			t.Errorf("error %q was classified as %s, expected %s", err, inspectErr.Code, expectedCode)
+		}
+		if inspectErr.Details["cause"] != err.Error() {
+			t.Errorf("error %q was not recorded as the cause", err)
+		}
+	}
+
+	dnsErr := ClassifyFetchError(&net.DNSError{Name: "nosuchhost", IsNotFound: true})
+	if dnsErr.Retryable || dnsErr.Details["host"] != "nosuchhost" {
+		t.Errorf("DNS error returned retryable %t and host %q, expected false and nosuchhost", dnsErr.Retryable, dnsErr.Details["host"])
+	}
+}
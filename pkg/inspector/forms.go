@@ -0,0 +1,470 @@
+package inspector
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+// Purposes of forms. See InspectedForm.Kind
+const (
+	FormKindLogin          = "login"
+	FormKindSignup         = "signup"
+	FormKindPasswordChange = "password_change"
+	FormKindPasswordReset  = "password_reset"
+	FormKindSearch         = "search"
+	FormKindNewsletter     = "newsletter"
+	FormKindOther          = "other"
+)
+
+// InspectedForm is a <form> of the page
+type InspectedForm struct {
+	// Purpose of the form. One of the FormKind constants
+	Kind string `json:"kind"`
+
+	// URL the form is submitted to, resolved against the page URL
+	Action  string `json:"action"`
+	Method  string `json:"method"`
+	Enctype string `json:"enctype"`
+
+	Fields []*FormField `json:"fields"`
+
+	// Text of the submit buttons
+	SubmitTexts []string `json:"submit_texts"`
+
+	// Security issues of the form. See the FormIssue constants
+	Issues []Problem `json:"issues"`
+
+	// Lowercase id, name, class and role of the form element, used to classify it
+	identity string
+}
+
+// FormField is an input, select or textarea of a form
+type FormField struct {
+	Name         string `json:"name"`
+	Type         string `json:"type"`
+	Required     bool   `json:"required"`
+	Autocomplete string `json:"autocomplete,omitempty"`
+
+	// Text of the <label> of the field, or its aria-label
+	Label string `json:"label,omitempty"`
+
+	// id attribute, to find <label for="id"> elements anywhere in the page
+	id string
+}
+
+// Security issues of forms. See InspectedForm.Issues
+const (
+	// The form of an HTTPS page is submitted over plain HTTP
+	FormIssueInsecureAction = "insecure_action"
+
+	// A password is submitted in the URL, which ends up in browser history and server logs
+	FormIssuePasswordViaGet = "password_via_get"
+
+	// A POST form has no hidden field that looks like a CSRF token
+	FormIssueMissingCSRFToken = "missing_csrf_token"
+
+	// A field for a card number, security code or social security number may be saved by the browser
+	FormIssueSensitiveAutocomplete = "sensitive_autocomplete"
+)
+
+// Parts of the names of hidden fields that hold CSRF tokens in common frameworks
+var csrfFieldNames = []string{"csrf", "xsrf", "token", "nonce", "__requestverificationtoken"}
+
+// Words of the names and autocomplete values of fields that should not be saved by browsers. See hasNameWords
+var sensitiveFieldNames = []string{"cc-number", "cc-csc", "cardnumber", "card-number", "cvv", "cvc", "csc", "ssn", "social-security"}
+
+// formLabel is a <label> being parsed
+type formLabel struct {
+	forID string
+	text  strings.Builder
+
+	// Field inside the label, which is labelled by it
+	field *FormField
+}
+
+// Words that hint the purpose of a form in its submit text, action URL, id or class
+var formKindKeywords = map[string][]string{
+	FormKindLogin:          {"log in", "login", "sign in", "signin", "log on", "logon", "session"},
+	FormKindSignup:         {"sign up", "signup", "register", "registration", "create account", "create an account", "join now"},
+	FormKindPasswordChange: {"change password", "update password", "new password"},
+	FormKindPasswordReset:  {"forgot", "reset", "recover"},
+	FormKindSearch:         {"search"},
+	FormKindNewsletter:     {"subscribe", "newsletter", "mailing list"},
+}
+
+// Names of search query fields
+var searchFieldNames = []string{"q", "query", "search", "s", "keyword", "keywords", "search_query"}
+
+// openForm starts a form. Nested forms are ignored, as browsers do
+func (report *InspectReport) openForm(tkn *html.Token) {
+	if report.currentForm != nil {
+		return
+	}
+
+	form := &InspectedForm{
+		Method:      strings.ToUpper(tokenAttribute(tkn, "method")),
+		Fields:      []*FormField{},
+		SubmitTexts: []string{},
+		Issues:      []Problem{},
+		identity:    strings.ToLower(strings.Join([]string{tokenAttribute(tkn, "id"), tokenAttribute(tkn, "name"), tokenAttribute(tkn, "class"), tokenAttribute(tkn, "role")}, " ")),
+	}
+
+	if form.Method != "POST" && form.Method != "DIALOG" {
+		form.Method = "GET"
+	}
+
+	form.Enctype = strings.ToLower(tokenAttribute(tkn, "enctype"))
+	if len(form.Enctype) == 0 {
+		form.Enctype = "application/x-www-form-urlencoded"
+	}
+
+	form.Action = report.resolveURL(tokenAttribute(tkn, "action"))
+
+	report.currentForm = form
+}
+
+// closeForm classifies the current form, and adds it to the report
+func (report *InspectReport) closeForm() {
+	form := report.currentForm
+	if form == nil {
+		return
+	}
+
+	form.Kind = classifyForm(form)
+	form.Issues = report.checkFormSecurity(form)
+	report.Forms = append(report.Forms, form)
+	report.currentForm = nil
+}
+
+// parseFormField adds an input, select or textarea to the current form
+func (report *InspectReport) parseFormField(tkn *html.Token) {
+	form := report.currentForm
+	if form == nil {
+		return
+	}
+
+	fieldType := strings.ToLower(tkn.Data)
+	if fieldType == "input" {
+		fieldType = strings.ToLower(tokenAttribute(tkn, "type"))
+		if len(fieldType) == 0 {
+			fieldType = "text"
+		}
+	}
+
+	// Submit inputs are buttons, not fields
+	if fieldType == "submit" || fieldType == "image" {
+		if submitText := tokenAttribute(tkn, "value"); len(submitText) > 0 {
+			form.SubmitTexts = append(form.SubmitTexts, removeHTMLEmptySpace(submitText))
+			report.detectAuthText(submitText)
+		}
+		return
+	}
+	if fieldType == "button" || fieldType == "reset" {
+		return
+	}
+
+	field := &FormField{
+		Name:         tokenAttribute(tkn, "name"),
+		Type:         fieldType,
+		Required:     tokenHasAttribute(tkn, "required"),
+		Autocomplete: strings.ToLower(strings.TrimSpace(tokenAttribute(tkn, "autocomplete"))),
+		Label:        removeHTMLEmptySpace(tokenAttribute(tkn, "aria-label")),
+		id:           tokenAttribute(tkn, "id"),
+	}
+	form.Fields = append(form.Fields, field)
+
+	// A label without a for attribute labels the field inside it
+	if report.currentLabel != nil && len(report.currentLabel.forID) == 0 && report.currentLabel.field == nil && field.Type != "hidden" {
+		report.currentLabel.field = field
+	}
+}
+
+// openLabel starts a <label>
+func (report *InspectReport) openLabel(tkn *html.Token) {
+	report.currentLabel = &formLabel{forID: tokenAttribute(tkn, "for")}
+}
+
+// parseText adds text of the page to the label being parsed
+func (report *InspectReport) parseText(text string) {
+	if report.currentLabel != nil {
+		report.currentLabel.text.WriteString(text)
+	}
+}
+
+// closeLabel labels the field inside the label, or keeps the label to find its field by id at the end of the page
+func (report *InspectReport) closeLabel() {
+	label := report.currentLabel
+	if label == nil {
+		return
+	}
+	report.currentLabel = nil
+
+	labelText := strings.TrimSpace(removeHTMLEmptySpace(label.text.String()))
+	if len(labelText) == 0 {
+		return
+	}
+
+	if label.field != nil {
+		label.field.Label = labelText
+	} else if len(label.forID) > 0 {
+		if report.labelsByID == nil {
+			report.labelsByID = map[string]string{}
+		}
+		report.labelsByID[label.forID] = labelText
+	}
+}
+
+// finishForms closes the form left open at the end of the page, and labels fields with <label for="id"> elements
+func (report *InspectReport) finishForms() {
+	report.closeLabel()
+	report.closeForm()
+
+	for _, form := range report.Forms {
+		for _, field := range form.Fields {
+			if labelText, hasLabel := report.labelsByID[field.id]; hasLabel && len(field.id) > 0 {
+				field.Label = labelText
+			}
+		}
+	}
+}
+
+// checkFormSecurity returns the security issues of a form
+func (report *InspectReport) checkFormSecurity(form *InspectedForm) []Problem {
+	issues := []Problem{}
+
+	if report.pageURL() != nil && report.pageURL().Scheme == "https" && strings.HasPrefix(strings.ToLower(form.Action), "http:") {
+		issues = append(issues, Problem{FormIssueInsecureAction, "The form of an HTTPS page is submitted over plain HTTP to " + form.Action})
+	}
+
+	hasPassword, hasCSRFToken := false, false
+
+	for _, field := range form.Fields {
+		fieldName := strings.ToLower(field.Name)
+
+		if field.Type == "password" {
+			hasPassword = true
+		}
+
+		if field.Type == "hidden" {
+			for _, csrfFieldName := range csrfFieldNames {
+				if strings.Contains(fieldName, csrfFieldName) {
+					hasCSRFToken = true
+				}
+			}
+		}
+
+		if field.Type != "hidden" && field.Autocomplete != "off" {
+			for _, sensitiveFieldName := range sensitiveFieldNames {
+				if hasNameWords(field.Name, sensitiveFieldName) || hasNameWords(field.Autocomplete, sensitiveFieldName) {
+					issues = append(issues, Problem{FormIssueSensitiveAutocomplete, "Field " + field.Name + " may be saved by the browser. Set autocomplete=\"off\""})
+					break
+				}
+			}
+		}
+	}
+
+	if hasPassword && form.Method == "GET" {
+		issues = append(issues, Problem{FormIssuePasswordViaGet, "The password is submitted in the URL with a GET request"})
+	}
+
+	if form.Method == "POST" && !hasCSRFToken {
+		issues = append(issues, Problem{FormIssueMissingCSRFToken, "The form has no hidden field that looks like a CSRF token"})
+	}
+
+	return issues
+}
+
+// parseButton adds the text of a submit button to the current form
+func (report *InspectReport) parseButton(tkn *html.Token, buttonText string) {
+	buttonType := strings.ToLower(tokenAttribute(tkn, "type"))
+	if report.currentForm == nil || (buttonType != "" && buttonType != "submit") {
+		return
+	}
+
+	if len(buttonText) == 0 {
+		buttonText = tokenAttribute(tkn, "value")
+	}
+
+	if len(buttonText) > 0 {
+		report.currentForm.SubmitTexts = append(report.currentForm.SubmitTexts, buttonText)
+	}
+}
+
+// resolveURL resolves a URL of the page against the URL the page was served from. An empty URL is the page itself
+func (report *InspectReport) resolveURL(rawURL string) string {
+	if report.pageURL() == nil {
+		return rawURL
+	}
+
+	resolvedURL, resolveErr := report.pageURL().Parse(strings.TrimSpace(rawURL))
+	if resolveErr != nil {
+		return rawURL
+	}
+	return resolvedURL.String()
+}
+
+// classifyForm decides the purpose of a form from its password fields and their autocomplete values,
+// the names and types of its fields, its submit text and its action URL
+func classifyForm(form *InspectedForm) string {
+	passwordCount, currentPasswordCount, newPasswordCount := 0, 0, 0
+	hasIdentityField, hasEmailField, hasSearchField := false, false, false
+	visibleFieldCount := 0
+
+	for _, field := range form.Fields {
+		autocomplete := " " + field.Autocomplete + " "
+		fieldName := strings.ToLower(field.Name)
+
+		if field.Type != "hidden" {
+			visibleFieldCount++
+		}
+
+		switch {
+		case field.Type == "password":
+			passwordCount++
+			if strings.Contains(autocomplete, " current-password ") {
+				currentPasswordCount++
+			} else if strings.Contains(autocomplete, " new-password ") {
+				newPasswordCount++
+			}
+
+		case field.Type == "search" || containsString(searchFieldNames, fieldName):
+			hasSearchField = true
+
+		case field.Type == "email" || strings.Contains(autocomplete, " email ") || strings.Contains(fieldName, "email"):
+			hasEmailField = true
+			hasIdentityField = true
+
+		case strings.Contains(autocomplete, " username ") || strings.Contains(fieldName, "user") || strings.Contains(fieldName, "login") ||
+			strings.Contains(autocomplete, " name ") || strings.Contains(autocomplete, " tel ") || field.Type == "tel":
+			hasIdentityField = true
+		}
+	}
+
+	hints := formHints(form)
+
+	if passwordCount > 0 {
+		switch {
+		// Changing a password needs the current one and a new one
+		case currentPasswordCount > 0 && (newPasswordCount > 0 || passwordCount >= 3):
+			return FormKindPasswordChange
+		case passwordCount >= 3 || (hints[FormKindPasswordChange] && !hints[FormKindSignup]):
+			return FormKindPasswordChange
+
+		// Choosing a new password, either for a new account or after a reset
+		case newPasswordCount > 0 || passwordCount == 2:
+			if hints[FormKindPasswordReset] && !hints[FormKindSignup] {
+				return FormKindPasswordReset
+			}
+			if hasIdentityField || hints[FormKindSignup] {
+				return FormKindSignup
+			}
+			return FormKindPasswordReset
+
+		// Sign up forms with a single password field are told apart by their text
+		case hints[FormKindSignup] && !hints[FormKindLogin] && currentPasswordCount == 0:
+			return FormKindSignup
+		}
+
+		return FormKindLogin
+	}
+
+	switch {
+	case hints[FormKindPasswordReset] && hasEmailField:
+		return FormKindPasswordReset
+	case hasSearchField || (hints[FormKindSearch] && visibleFieldCount <= 2):
+		return FormKindSearch
+	case hasEmailField && hints[FormKindNewsletter] && visibleFieldCount <= 3:
+		return FormKindNewsletter
+	case hasIdentityField && hints[FormKindSignup]:
+		return FormKindSignup
+	}
+
+	return FormKindOther
+}
+
+// formHints returns the purposes hinted by the submit text, action URL, id, name, class and role of the form
+func formHints(form *InspectedForm) map[string]bool {
+	text := strings.ToLower(strings.Join(form.SubmitTexts, " ") + " " + form.Action + " " + form.identity)
+
+	// "sign-up", "sign_up" and "/sign/up" all mean "sign up"
+	text = strings.NewReplacer("-", " ", "_", " ", "/", " ").Replace(text)
+
+	hints := map[string]bool{}
+	for kind, keywords := range formKindKeywords {
+		for _, keyword := range keywords {
+			if strings.Contains(text, keyword) {
+				hints[kind] = true
+				break
+			}
+		}
+	}
+
+	return hints
+}
+
+// nameWords splits a name into lowercase words at separators and case changes,
+// such as "cardNumber", "card_number" and "card-number" into "card" and "number"
+func nameWords(name string) []string {
+	words := []string{}
+	runes := []rune(name)
+	start := -1
+
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if start >= 0 {
+				words = append(words, strings.ToLower(string(runes[start:i])))
+				start = -1
+			}
+			continue
+		}
+
+		// A new word starts at an upper case letter after a lower case one, or at the last upper case letter of an acronym before a lower case one
+		if start >= 0 && unicode.IsUpper(r) {
+			previous := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || (unicode.IsUpper(previous) && nextIsLower) {
+				words = append(words, strings.ToLower(string(runes[start:i])))
+				start = i
+			}
+		}
+
+		if start < 0 {
+			start = i
+		}
+	}
+
+	if start >= 0 {
+		words = append(words, strings.ToLower(string(runes[start:])))
+	}
+
+	return words
+}
+
+// hasNameWords reports whether the words of pattern appear in a row among the words of name. See nameWords.
+// Unlike a substring match, "ssn" is not found in "classname", nor "auth" in "author"
+func hasNameWords(name string, pattern string) bool {
+	nameParts := nameWords(name)
+	patternParts := nameWords(pattern)
+
+	if len(patternParts) == 0 {
+		return false
+	}
+
+	for i := 0; i+len(patternParts) <= len(nameParts); i++ {
+		matches := true
+		for j, patternPart := range patternParts {
+			if nameParts[i+j] != patternPart {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,191 @@
+package inspector
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestClassifyForms(t *testing.T) {
+	expectedKinds := map[string]string{
+		// Autocomplete values decide password forms
+		`<form action="/session"><input type="email" autocomplete="username"><input type="password" autocomplete="current-password"><button>Continue</button></form>`:                   FormKindLogin,
+		`<form><input name="email" type="email"><input type="password" autocomplete="new-password"><button type="submit">Continue</button></form>`:                                      FormKindSignup,
+		`<form><input type="password" autocomplete="current-password"><input type="password" autocomplete="new-password"><input type="password" autocomplete="new-password"></form>`:    FormKindPasswordChange,
+		`<form action="/password/reset?token=abc"><input type="password" name="password"><input type="password" name="password_confirmation"><input type="submit" value="Save"></form>`: FormKindPasswordReset,
+
+		// Without autocomplete values, the submit text and action tell them apart
+		`<form action="/users"><input name="user[login]"><input type="password" name="user[password]"><input type="submit" value="Sign in"></form>`:                   FormKindLogin,
+		`<form action="/join"><input name="username"><input type="password" name="password"><button><span>Create account</span></button></form>`:                      FormKindSignup,
+		`<form id="signup-form"><input name="name"><input name="email"><input type="password" name="pw"><input type="password" name="pw2"><button>Go</button></form>`: FormKindSignup,
+
+		// Forms without password fields
+		`<form action="/forgot-password"><input type="email" name="email"><button>Send reset link</button></form>`:                                      FormKindPasswordReset,
+		`<form role="search" action="/find"><input name="q"><button>Go</button></form>`:                                                                 FormKindSearch,
+		`<form action="/newsletter"><input type="email" name="email"><button>Subscribe</button></form>`:                                                 FormKindNewsletter,
+		`<form action="/contact" method="post"><input name="name"><input name="email"><textarea name="message"></textarea><button>Send</button></form>`: FormKindOther,
+	}
+
+	for page, expectedKind := range expectedKinds {
+		report := inspectURLResponse("https://example.com", &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(page))}, nil, nil)
+
+		if len(report.Forms) != 1 {
+			t.Errorf("found %d forms, expected 1 in %s", len(report.Forms), page)
+			continue
+		}
+		if report.Forms[0].Kind != expectedKind {
+			t.Errorf("form was classified as %s, expected %s : %s", report.Forms[0].Kind, expectedKind, page)
+		}
+	}
+}
+
+func TestParseForms(t *testing.T) {
+	page := `<form method="post" action="login"><input type="hidden" name="csrf" value="x"><input type="password" name="password"><input type="submit" value="Log in">
+		<form action="/nested"><input name="ignored"></form>
+		<form><input type="search" name="q">`
+
+	report := inspectURLResponse("https://example.com/account", &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(page))}, nil, nil)
+
+	if len(report.Forms) != 2 {
+		t.Fatalf("found %d forms, expected 2", len(report.Forms))
+	}
+
+	login := report.Forms[0]
+	if login.Action != "https://example.com/login" || login.Method != "POST" || len(login.Fields) != 3 || login.SubmitTexts[0] != "Log in" {
+		t.Errorf("login form was parsed as %+v", login)
+	}
+	if report.LoginFieldCount != 1 {
+		t.Errorf("returned %d login fields, expected 1", report.LoginFieldCount)
+	}
+
+	// The unclosed form at the end of the document
+	if search := report.Forms[1]; search.Kind != FormKindSearch || search.Action != "https://example.com/account" || search.Method != "GET" {
+		t.Errorf("search form was parsed as %+v", search)
+	}
+}
+
+func TestFormsWithIconOnlyButtons(t *testing.T) {
+	// Buttons and links without text end at their own end tag, before the end of their form
+	page := `<form action="/session"><input name="username"><input type="password" name="password" autocomplete="current-password"><button type="submit"><img src="/go.png"></button></form>
+		<form role="search" action="/find"><input name="q"><a href="/help"><img src="/help.png"></a></form>
+		<p>Forgot your password?</p>`
+
+	report := inspectURLResponse("https://example.com", &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(page))}, nil, nil)
+
+	if len(report.Forms) != 2 {
+		t.Fatalf("found %d forms, expected 2", len(report.Forms))
+	}
+	if login := report.Forms[0]; login.Kind != FormKindLogin || len(login.Fields) != 2 || len(login.SubmitTexts) != 0 {
+		t.Errorf("login form was parsed as %+v", login)
+	}
+	if search := report.Forms[1]; search.Kind != FormKindSearch || len(search.Fields) != 1 {
+		t.Errorf("search form was parsed as %+v", search)
+	}
+	if len(report.Links) != 1 || report.Links[0].Text != "" {
+		t.Errorf("returned links %+v, expected /help without text", report.Links)
+	}
+}
+
+func TestFormLabels(t *testing.T) {
+	page := `<form method="post" enctype="multipart/form-data">
+		<label>Email <input type="email" name="email" required></label>
+		<input type="password" name="password" id="pw"><input name="nick" aria-label="Nickname">
+		</form><label for="pw">Password</label>`
+
+	report := inspectURLResponse("https://example.com", &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(page))}, nil, nil)
+
+	if len(report.Forms) != 1 {
+		t.Fatalf("found %d forms, expected 1", len(report.Forms))
+	}
+
+	form := report.Forms[0]
+	if form.Enctype != "multipart/form-data" {
+		t.Errorf("returned enctype %s", form.Enctype)
+	}
+
+	expectedLabels := []string{"Email", "Password", "Nickname"}
+	for i, field := range form.Fields {
+		if field.Label != expectedLabels[i] {
+			t.Errorf("field %s has label %q, expected %q", field.Name, field.Label, expectedLabels[i])
+		}
+	}
+	if !form.Fields[0].Required || form.Fields[1].Required {
+		t.Errorf("required fields were not detected")
+	}
+}
+
+func TestFormIssues(t *testing.T) {
+	tests := []struct {
+		name     string
+		page     string
+		expected []string
+	}{
+		{"secure", `<form method="post"><input type="hidden" name="authenticity_token"><input type="password" name="password"></form>`, []string{}},
+		{"insecure action", `<form method="post" action="http://example.com/login"><input type="hidden" name="_csrf"></form>`, []string{FormIssueInsecureAction}},
+		{"password via get", `<form><input type="password" name="password"></form>`, []string{FormIssuePasswordViaGet}},
+		{"missing csrf token", `<form method="post"><input name="email"></form>`, []string{FormIssueMissingCSRFToken}},
+		{"card number", `<form><input name="card_number"><input name="cvc" autocomplete="off"></form>`, []string{FormIssueSensitiveAutocomplete}},
+		{"camel case", `<form method="post"><input type="hidden" name="_csrf"><input name="cardNumber"><input name="userSSN"></form>`, []string{FormIssueSensitiveAutocomplete, FormIssueSensitiveAutocomplete}},
+		{"ordinary names", `<form method="post"><input type="hidden" name="_csrf"><input name="classname"><input name="businessname"></form>`, []string{}},
+	}
+
+	for _, test := range tests {
+		report := inspectURLResponse("https://example.com", &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(test.page))}, nil, nil)
+		issues := report.Forms[0].Issues
+
+		if len(issues) != len(test.expected) {
+			t.Errorf("%s: returned issues %+v, expected %v", test.name, issues, test.expected)
+			continue
+		}
+		for i, issue := range issues {
+			if issue.Code != test.expected[i] {
+				t.Errorf("%s: returned issue %s, expected %s", test.name, issue.Code, test.expected[i])
+			}
+		}
+	}
+}
+
+func TestFormIssuesAfterRedirect(t *testing.T) {
+	tests := []struct {
+		inputURL string
+		finalURL string
+		page     string
+		expected []string
+	}{
+		// The relative action of a page upgraded to HTTPS is submitted over HTTPS
+		{"http://example.com", "https://example.com/", `<form method="post" action="/login"><input type="hidden" name="_csrf"></form>`, []string{}},
+		{"http://example.com", "https://example.com/", `<form method="post" action="http://example.com/login"><input type="hidden" name="_csrf"></form>`, []string{FormIssueInsecureAction}},
+
+		// A page downgraded to plain HTTP is insecure as a whole, not because of its form
+		{"https://example.com", "http://example.com/", `<form method="post" action="http://example.com/login"><input type="hidden" name="_csrf"></form>`, []string{}},
+	}
+
+	for _, test := range tests {
+		finalRequest, _ := http.NewRequest(http.MethodGet, test.finalURL, nil)
+		report := inspectURLResponse(test.inputURL, &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(test.page)), Request: finalRequest}, nil, nil)
+		issues := report.Forms[0].Issues
+
+		if len(issues) != len(test.expected) || (len(issues) > 0 && issues[0].Code != test.expected[0]) {
+			t.Errorf("%s redirected to %s: returned issues %+v, expected %v", test.inputURL, test.finalURL, issues, test.expected)
+		}
+	}
+}
+
+func TestNameWords(t *testing.T) {
+	expectedWords := map[string]string{
+		"card_number":       "card number",
+		"cardNumber":        "card number",
+		"cc-csc":            "cc csc",
+		"userSSN":           "user ssn",
+		"SSNumber":          "ss number",
+		"ASP.NET_SessionId": "asp net session id",
+		"classname":         "classname",
+	}
+
+	for name, expected := range expectedWords {
+		if words := strings.Join(nameWords(name), " "); words != expected {
+			t.Errorf("name %q was split into %q, expected %q", name, words, expected)
+		}
+	}
+}
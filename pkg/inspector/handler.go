@@ -0,0 +1,146 @@
+package inspector
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Base URL of pages served by InspectHandler
+const HandlerBaseURL = "http://localhost"
+
+// Time limit of the link checks of InspectHandler, when Options.LinkAnalyticsDeadline is not set
+var HandlerInspectionTimeout = 30 * time.Second
+
+// InspectHandler inspects the page at path served by the handler, without using the network.
+// Internal links are checked against the same handler. It waits for the link analysis, and returns the final report.
+//
+// This lets web apps assert on their pages in plain go test:
+//
+//	report := inspector.InspectHandler(app.Router(), "/login", nil)
+//	if report.InaccessibleLinkCount > 0 { ... }
+//
+// Only internal links (absolute and relative) are checked unless Options.LinkTypes says otherwise.
+// Requests to other hosts fail, as they are not served by the handler.
+func InspectHandler(handler http.Handler, path string, opts *Options) *InspectReport {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	report := InspectURLWithOptions(HandlerBaseURL+path, handlerOptions(handler, opts))
+	report.LinkAnalyticWG.Wait()
+	report.FinishLinkAnalysis()
+	report.CountLinks()
+
+	return report
+}
+
+// handlerOptions returns a copy of opts that sends the requests of the inspection to the handler
+func handlerOptions(handler http.Handler, opts *Options) *Options {
+	handlerOpts := Options{}
+	if opts != nil {
+		handlerOpts = *opts
+	}
+
+	handlerOpts.transport = &handlerTransport{handler: handler, host: strings.TrimPrefix(HandlerBaseURL, "http://")}
+
+	if len(handlerOpts.LinkTypes) == 0 {
+		handlerOpts.LinkTypes = []string{"absolute", "relative"}
+	}
+
+	if handlerOpts.LinkAnalyticsDeadline == nil {
+		deadline := time.Now().Add(HandlerInspectionTimeout)
+		handlerOpts.LinkAnalyticsDeadline = &deadline
+	}
+
+	return &handlerOpts
+}
+
+// handlerTransport serves the requests to host with the handler
+type handlerTransport struct {
+	handler http.Handler
+	host    string
+}
+
+func (transport *handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != transport.host {
+		return nil, fmt.Errorf("%s is not served by the inspected handler", req.URL.Host)
+	}
+
+	if ctxErr := req.Context().Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+
+	// Handlers see the request as a server would
+	serverReq := req.Clone(req.Context())
+	serverReq.RequestURI = req.URL.RequestURI()
+	serverReq.RemoteAddr = "127.0.0.1:0"
+	if serverReq.Body == nil {
+		serverReq.Body = http.NoBody
+	}
+
+	recorder := &responseRecorder{header: http.Header{}}
+	transport.handler.ServeHTTP(recorder, serverReq)
+
+	resp := recorder.response()
+	resp.Request = req
+	return resp, nil
+}
+
+// responseRecorder keeps the response written by a handler in memory
+type responseRecorder struct {
+	header http.Header
+	body   bytes.Buffer
+
+	// Status code and headers as they were when the handler wrote the header
+	statusCode    int
+	writtenHeader http.Header
+}
+
+func (recorder *responseRecorder) Header() http.Header {
+	return recorder.header
+}
+
+func (recorder *responseRecorder) WriteHeader(statusCode int) {
+	if recorder.writtenHeader != nil {
+		return
+	}
+
+	recorder.statusCode = statusCode
+	recorder.writtenHeader = recorder.header.Clone()
+}
+
+func (recorder *responseRecorder) Write(data []byte) (int, error) {
+	if recorder.writtenHeader == nil {
+		// Like a server, detect the content type of handlers that don't set it
+		if len(recorder.header.Get("Content-Type")) == 0 {
+			recorder.header.Set("Content-Type", http.DetectContentType(data))
+		}
+		recorder.WriteHeader(http.StatusOK)
+	}
+
+	return recorder.body.Write(data)
+}
+
+// Flush is a no-op, so handlers that stream their response can be inspected
+func (recorder *responseRecorder) Flush() {}
+
+// response returns the recorded response, as the HTTP client would receive it
+func (recorder *responseRecorder) response() *http.Response {
+	recorder.WriteHeader(http.StatusOK)
+
+	return &http.Response{
+		Status:        strconv.Itoa(recorder.statusCode) + " " + http.StatusText(recorder.statusCode),
+		StatusCode:    recorder.statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        recorder.writtenHeader,
+		Body:          io.NopCloser(bytes.NewReader(recorder.body.Bytes())),
+		ContentLength: int64(recorder.body.Len()),
+	}
+}
@@ -0,0 +1,37 @@
+package inspector
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestInspectHandler(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/about", func(wr http.ResponseWriter, req *http.Request) {
+		wr.Write([]byte("about"))
+	})
+	mux.HandleFunc("/login", func(wr http.ResponseWriter, req *http.Request) {
+		wr.Write([]byte(`<!DOCTYPE html><html><head><title>Sign in</title></head><body>
+			<h1>Sign in</h1>
+			<form><input type="email" name="email"><input type="password" name="password"></form>
+			<a href="/about">About</a>
+			<a href="/missing">Missing</a>
+			<a href="https://example.com/">External</a>
+		</body></html>`))
+	})
+
+	report := InspectHandler(mux, "/login", nil)
+
+	if report.StatusCode != 200 || report.PageTitle != "Sign in" || report.LoginFieldCount != 1 {
+		t.Errorf("returned status %d, title %q and %d login fields", report.StatusCode, report.PageTitle, report.LoginFieldCount)
+	}
+
+	if report.AccessibleLinkCount != 1 || report.InaccessibleLinkCount != 1 || report.NotAnalysedLinkCount != 1 {
+		t.Errorf("returned %d accessible, %d inaccessible and %d not analysed links, expected 1, 1 and 1",
+			report.AccessibleLinkCount, report.InaccessibleLinkCount, report.NotAnalysedLinkCount)
+	}
+
+	if report := InspectHandler(mux, "missing", nil); report.StatusCode != http.StatusNotFound {
+		t.Errorf("missing page returned status %d, expected 404", report.StatusCode)
+	}
+}
@@ -2,6 +2,9 @@ package inspector
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"net/url"
 	"regexp"
@@ -13,16 +16,21 @@ import (
 	"golang.org/x/net/html"
 )
 
+// Maximum number of link checks running at once, shared fairly by all inspections. See linkScheduler
 var MaximumConcurrentLinkAnalysis = 256
 
-// Control the number of concurrent link analysers
-var concurrentLinkAnalysersSemaphore chan struct{} = make(chan struct{}, MaximumConcurrentLinkAnalysis)
+// Link checks are not scheduled when less than this much time is left before the link analytics deadline.
+// Such checks rarely complete, and they only delay the final report.
+var LinkCheckSchedulingMargin = 500 * time.Millisecond
 
 type InspectReport struct {
 	URL        string `json:"url"`
 	StatusCode int    `json:"status_code"`
 	StatusMsg  string `json:"status_msg"`
 
+	// Set if the web page could not be fetched or parsed
+	Error *InspectError `json:"error,omitempty"`
+
 	HTMLVersion string `json:"html_version"`
 	PageTitle   string `json:"page_title"`
 
@@ -32,19 +40,105 @@ type InspectReport struct {
 	// Number of password fields in the page
 	LoginFieldCount int `json:"login_field_count"`
 
+	// Ways to sign in offered by the page, such as passwords, passkeys and identity providers. See the AuthMethod constants
+	AuthMethods []string `json:"auth_methods"`
+
+	// Identity providers of the page's "Sign in with" buttons and links, such as google or github
+	SignInProviders []string `json:"sign_in_providers"`
+
+	// CAPTCHA widgets embedded in the page, such as recaptcha or turnstile. See the Captcha constants
+	Captchas []string `json:"captchas"`
+
+	// Set if the URL returned a bot challenge page instead of its content
+	Challenge *Challenge `json:"challenge,omitempty"`
+
+	// Grade of the security response headers. Not set if the page could not be fetched, or for InspectHTML
+	SecurityHeaders *SecurityHeaderAudit `json:"security_headers,omitempty"`
+
+	// Cookies set by the page response and the redirects before it
+	Cookies []*InspectedCookie `json:"cookies"`
+
+	// Set if certificates were not verified, as requested with Options.InsecureSkipVerify
+	InsecureTLS bool `json:"insecure_tls,omitempty"`
+
+	// TLS connection and certificate chain of the page. For certificate errors, only the failed certificate is described
+	TLS *TLSDetails `json:"tls,omitempty"`
+
+	// TLS details of the hosts of checked links, by host name. Only set with Options.CheckLinkTLS
+	LinkTLS map[string]*TLSDetails `json:"link_tls,omitempty"`
+
+	// Resources and links of an HTTPS page that use plain HTTP
+	MixedContent []*MixedContent `json:"mixed_content"`
+
+	// Forms of the page, classified by their purpose, such as login or signup
+	Forms []*InspectedForm `json:"forms"`
+
 	Links []*InspectedLink `json:"links"`
 
 	AccessibleLinkCount   int `json:"accessible_link_count"`
 	InaccessibleLinkCount int `json:"inaccessible_link_count"`
 	NotAnalysedLinkCount  int `json:"not_analysed_link_count"`
+	UnfinishedLinkCount   int `json:"unfinished_link_count"`
 	TotalLinkCount        int `json:"total_link_count"`
 	ExternalLinkCount     int `json:"external_link_count"`
 	InternalLinkCount     int `json:"internal_link_count"`
 
+	// Estimated broken link rates, when only a sample of the links is checked. See Options.SampleSize
+	LinkSample *LinkSample `json:"link_sample,omitempty"`
+
+	// Token to resume analysing the unfinished links in a later request. See ResumeInspection
+	Continuation string `json:"continuation,omitempty"`
+
 	LinkAnalyticWG       *sync.WaitGroup    `json:"-"`
 	RequestContext       *context.Context   `json:"-"`
 	RequestContextCancel context.CancelFunc `json:"-"`
 	ParsedURL            *url.URL           `json:"-"`
+	Options              *Options           `json:"-"`
+	HTTPClient           *http.Client       `json:"-"`
+
+	// URL the page was served from, after redirects. See pageURL
+	finalURL *url.URL
+
+	// Link results of the requests before a resumed inspection. See ResumeInspection
+	previousLinks *linkResults
+
+	// Links waiting for the end of parsing to be analysed in the order of Options.LinkOrder
+	pendingLinks []*InspectedLink
+
+	// Form being parsed, until its end tag
+	currentForm *InspectedForm
+
+	// Set if the page loads a script of the Cloudflare challenge platform
+	hasChallengeScript bool
+
+	// Guards the results of Links (StatusCode, Type and Unfinished) and LinkTLS, which are written by concurrent link checks
+	// while the report is counted and encoded
+	linksLock *sync.RWMutex
+
+	// Content-Security-Policy of <meta http-equiv> tags
+	metaCSP []string
+
+	// Label being parsed, and the labels with a for attribute by the id of their field
+	currentLabel *formLabel
+	labelsByID   map[string]string
+
+	// Levels of all headings in document order, such as "h1", "h2", "h2". See Problems
+	headingOrder []string
+
+	// Headings, links and buttons waiting for their text, in the order they were opened
+	textElements []*textElement
+
+	// Landmark elements (main, nav, footer...) enclosing the current token while parsing, innermost last
+	openLandmarks []string
+
+	// Pending link checks of this inspection in linkAnalysisScheduler
+	linkQueue *linkQueue
+}
+
+// textElement is a heading, link or button being parsed, with its start tag
+type textElement struct {
+	tag string
+	tkn html.Token
 }
 
 type InspectedLink struct {
@@ -52,6 +146,18 @@ type InspectedLink struct {
 	Text       string `json:"text"`
 	Type       string `json:"type"`
 	StatusCode int    `json:"status_code"`
+
+	// True if the link was due to be analysed, but the link analytics deadline was reached first
+	Unfinished bool `json:"unfinished,omitempty"`
+
+	// Innermost landmark element containing the link, such as main, nav or footer. Empty if there is none
+	Section string `json:"section,omitempty"`
+
+	// Stratum ("type|host") of the link if it is part of the sample. See Options.SampleSize
+	Stratum string `json:"stratum,omitempty"`
+
+	// Set once the link is queued to be analysed. Links left out by Options.LinkTypes, MaxLinks or SampleSize are never queued
+	queued bool
 }
 
 // InspectURL returns an InspectReport for the given URL immediately, and continues to analyse the links in the background
@@ -61,84 +167,204 @@ type InspectedLink struct {
 //
 // Pass nil for linkAnalyticsTimout to avoid link analytics.
 func InspectURL(inputURL string, linkAnalyticsTimout *time.Time) *InspectReport {
+	return InspectURLWithOptions(inputURL, &Options{LinkAnalyticsDeadline: linkAnalyticsTimout})
+}
+
+// InspectURLWithOptions is InspectURL with control over how the web page and its links are inspected
+func InspectURLWithOptions(inputURL string, opts *Options) *InspectReport {
+
+	normalizedURL, urlErr := NormalizeURL(inputURL)
+	if urlErr != nil {
+		return inspectURLResponse(strings.TrimSpace(inputURL), nil, urlErr, opts)
+	}
 
-	inputURL = strings.TrimSpace(inputURL)
+	if opts == nil {
+		opts = &Options{}
+	}
 
-	if !strings.HasPrefix(inputURL, "https://") && !strings.HasPrefix(inputURL, "http://") {
-		inputURL = "https://" + inputURL
+	// The webpage itself must also be fetched before the deadline
+	fetchContext := context.Background()
+	if opts.LinkAnalyticsDeadline != nil {
+		var fetchContextCancel context.CancelFunc
+		fetchContext, fetchContextCancel = context.WithDeadline(fetchContext, *opts.LinkAnalyticsDeadline)
+		defer fetchContextCancel()
+	}
+	if opts.Timeout > 0 {
+		var fetchContextCancel context.CancelFunc
+		fetchContext, fetchContextCancel = context.WithTimeout(fetchContext, opts.Timeout)
+		defer fetchContextCancel()
 	}
 
-	inputURL = strings.TrimSuffix(inputURL, "/")
+	// Log in first, so the web page is fetched with the session
+	if opts.Login != nil {
+		loggedInOpts, loginErr := opts.logIn(fetchContext, normalizedURL)
+		if loginErr != nil {
+			return inspectURLResponse(normalizedURL, nil, loginErr, opts)
+		}
+		opts = loggedInOpts
+	}
 
 	// Get the webpage
-	httpResp, httpErr := http.Get(inputURL)
+	var httpResp *http.Response
+	httpReq, httpErr := http.NewRequestWithContext(fetchContext, http.MethodGet, normalizedURL, nil)
+	if httpErr == nil {
+		opts.addCredentials(httpReq)
+		httpResp, httpErr = opts.newHTTPClient(httpReq.URL).Do(httpReq)
+	}
+
+	if httpResp != nil {
+		defer httpResp.Body.Close()
+	}
 
 	// Return the report
-	return inspectURLResponse(inputURL, httpResp, httpErr, linkAnalyticsTimout)
+	return inspectURLResponse(normalizedURL, httpResp, httpErr, opts)
 }
 
-// Helper function for InspectURL. This is refractored to simplify unit testing.
-func inspectURLResponse(inputURL string, httpResp *http.Response, httpErr error, linkAnalyticsTimout *time.Time) *InspectReport {
+// InspectHTML inspects an HTML document that is already available, such as build output that isn't deployed yet.
+// Relative links are resolved against baseURL, which is where the document would be served from.
+//
+// The document is reported with status 200. Links are analysed in the background as in InspectURLWithOptions.
+func InspectHTML(document io.Reader, baseURL string, opts *Options) *InspectReport {
 
-	// Initialize the report with default values
-	report := InspectReport{
-		URL: inputURL,
+	normalizedURL, urlErr := NormalizeURL(baseURL)
+	if urlErr != nil {
+		return inspectURLResponse(strings.TrimSpace(baseURL), nil, urlErr, opts)
+	}
 
-		HTMLVersion: `Not defined`,
-		PageTitle:   `Not defined`,
+	httpResp := &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     "200 OK",
+		Header:     http.Header{"Content-Type": []string{"text/html"}},
+		Body:       io.NopCloser(document),
+	}
 
-		Links:    []*InspectedLink{},
-		Headings: map[string][]string{},
+	report := inspectURLResponse(normalizedURL, httpResp, nil, opts)
 
-		LinkAnalyticWG: &sync.WaitGroup{},
-	}
+	// The document has no response headers to audit
+	report.SecurityHeaders = nil
 
-	if linkAnalyticsTimout != nil {
-		// Create a context with a timeout
-		reqContextTimout, reqContextCancel := context.WithDeadline(context.Background(), *linkAnalyticsTimout)
-		report.RequestContext = &reqContextTimout
-		report.RequestContextCancel = reqContextCancel
-	} else {
-		// Set the context to nil
-		report.RequestContext = nil
-		report.RequestContextCancel = func() {}
+	return report
+}
+
+// Helper function for InspectURL. This is refractored to simplify unit testing.
+func inspectURLResponse(inputURL string, httpResp *http.Response, httpErr error, opts *Options) *InspectReport {
+
+	if opts == nil {
+		opts = &Options{}
 	}
 
+	report := newInspectReport(inputURL, opts)
+
 	parsedURL, parsedURLErr := url.Parse(inputURL)
 	if parsedURLErr != nil {
 		report.StatusCode = http.StatusBadRequest
-		report.StatusMsg = "URL not in valid format"
-		return &report
+		report.Error = NewInspectError(ErrorCodeInvalidURL, "URL not in valid format", false, parsedURLErr)
+		report.StatusMsg = report.Error.Message
+		return report
 	}
 
 	report.ParsedURL = parsedURL
+	report.HTTPClient = opts.newLinkHTTPClient(parsedURL)
+
+	// Redirects may change the scheme of the page
+	if httpResp != nil && httpResp.Request != nil && httpResp.Request.URL != nil {
+		report.finalURL = httpResp.Request.URL
+	}
 
 	// If there was an error getting the webpage, return an error
 	if httpErr != nil {
+		report.Error = ClassifyFetchError(httpErr)
+		report.TLS = tlsDetailsFromError(parsedURL.Hostname(), httpErr)
 
 		if httpResp != nil {
 			report.StatusCode = httpResp.StatusCode
 			report.StatusMsg = httpResp.Status
 		} else {
 			report.StatusCode = http.StatusBadRequest
-			report.StatusMsg = httpErr.Error()
+			report.StatusMsg = report.Error.Message
 		}
-		return &report
+		return report
 	}
 
 	report.StatusCode = httpResp.StatusCode
 	report.StatusMsg = httpResp.Status
+	report.TLS = newTLSDetails(httpResp)
 
 	tokenizer := html.NewTokenizer(httpResp.Body)
 	report.ParseTokens(tokenizer)
+	report.detectChallenge(httpResp)
+	report.SecurityHeaders = report.auditSecurityHeaders(httpResp.Header)
+	report.Cookies = report.auditCookies(httpResp)
 
 	report.TotalLinkCount = len(report.Links)
 
-	return &report
+	return report
+}
+
+// newInspectReport returns a report of inputURL with default values, before anything is inspected
+func newInspectReport(inputURL string, opts *Options) *InspectReport {
+	report := &InspectReport{
+		URL: inputURL,
+
+		HTMLVersion: `Not defined`,
+		PageTitle:   `Not defined`,
+
+		Links:    []*InspectedLink{},
+		Headings: map[string][]string{},
+		Forms:    []*InspectedForm{},
+
+		AuthMethods:     []string{},
+		SignInProviders: []string{},
+		Captchas:        []string{},
+		Cookies:         []*InspectedCookie{},
+		MixedContent:    []*MixedContent{},
+
+		LinkAnalyticWG: &sync.WaitGroup{},
+		linksLock:      &sync.RWMutex{},
+		Options:        opts,
+	}
+
+	report.setLinkAnalyticsDeadline(opts.LinkAnalyticsDeadline)
+	report.InsecureTLS = opts.InsecureSkipVerify
+
+	return report
 }
 
-// ParseTokens parses the HTML tokens from the given tokenizer
+// pageURL returns the URL the page was served from after redirects, or the inspected URL if it is not known
+func (report *InspectReport) pageURL() *url.URL {
+	if report.finalURL != nil {
+		return report.finalURL
+	}
+	return report.ParsedURL
+}
+
+// setLinkAnalyticsDeadline creates the context that link analysers run in.
+// Pass nil to avoid link analytics.
+func (report *InspectReport) setLinkAnalyticsDeadline(linkAnalyticsTimout *time.Time) {
+	if linkAnalyticsTimout != nil {
+		// Create a context with a timeout
+		reqContextTimout, reqContextCancel := context.WithDeadline(context.Background(), *linkAnalyticsTimout)
+		report.RequestContext = &reqContextTimout
+		report.RequestContextCancel = reqContextCancel
+	} else {
+		// Set the context to nil
+		report.RequestContext = nil
+		report.RequestContextCancel = func() {}
+	}
+}
+
+// ParseTokens parses the HTML tokens from the given tokenizer, and starts analysing the links found in them
 func (report *InspectReport) ParseTokens(tokenizer *html.Tokenizer) {
+	if report.Options == nil {
+		report.Options = &Options{}
+	}
+
+	// Links are analysed in order of importance, which is only known after parsing all of them
+	defer report.startLinkAnalysis()
+
+	// A form may not be closed before the end of the document, and labels may come after their fields
+	defer report.finishForms()
+
 	for {
 		var tokenType html.TokenType
 		var tkn html.Token
@@ -147,37 +373,14 @@ func (report *InspectReport) ParseTokens(tokenizer *html.Tokenizer) {
 		var nextToken = func() {
 			tokenType = tokenizer.Next()
 			tkn = tokenizer.Token()
-		}
-
-		// Some tags like <h1>, <a> tags could contain other tags instead of directly containing text
-		// So we need to parse the inner tags by skipping other tags
-		var parseNextTextToken = func() (string, bool) {
+			report.trackLandmark(tokenType, &tkn)
 
-			// depth is the level of nested tags inside this tag
-			depth := 0
-
-			for (tokenType != html.TextToken) && (tokenType != html.ErrorToken) && (depth >= 0) {
-				nextToken()
-
-				if tokenType == html.StartTagToken {
-					depth++
-				} else if tokenType == html.EndTagToken {
-					depth--
-				}
+			// Resources, widgets and sign in URLs are detected on every tag, wherever it is nested
+			if tokenType == html.StartTagToken || tokenType == html.SelfClosingTagToken {
+				report.detectAuthAttributes(&tkn)
+				report.detectCaptcha(&tkn)
+				report.detectMixedContent(&tkn)
 			}
-
-			if tokenType == html.TextToken {
-				tagText := removeHTMLEmptySpace(tkn.Data)
-				return tagText, false
-
-			} else if tokenType == html.ErrorToken {
-				// If there was an error parsing the HTML return
-				// this is most likely due to reaching the end of the HTML
-				// it is also probably because the HTML is malformed
-				return "", true
-			}
-
-			return "", false
 		}
 
 		nextToken()
@@ -189,10 +392,11 @@ func (report *InspectReport) ParseTokens(tokenizer *html.Tokenizer) {
 			report.HTMLVersion = DetectHTMLVersion(tknData)
 
 		case html.ErrorToken:
+			// Elements left open at the end of the document have no more text to wait for
+			report.closeTextElements()
 			return
 
 		case html.StartTagToken:
-
 			switch tknData {
 
 			case "title":
@@ -202,48 +406,132 @@ func (report *InspectReport) ParseTokens(tokenizer *html.Tokenizer) {
 				}
 
 			case "h1", "h2", "h3", "h4", "h5", "h6":
-				headingType := tknData
+				report.headingOrder = append(report.headingOrder, tknData)
+				report.openTextElement(tknData, tkn)
 
-				// To get the heading text
-				tagText, shouldReturn := parseNextTextToken()
-				if shouldReturn {
-					return
-				}
+			case "a", "button":
+				report.openTextElement(tknData, tkn)
 
-				if len(tagText) > 0 {
-					report.Headings[headingType] = append(report.Headings[headingType], tagText)
-				}
+			case "input":
+				report.parseInputTag(&tkn)
+
+			case "form":
+				report.openForm(&tkn)
+
+			case "select", "textarea":
+				report.parseFormField(&tkn)
+
+			case "label":
+				report.openLabel(&tkn)
 
-			case "a":
-				linkTk := tkn
+			case "meta":
+				report.parseMeta(&tkn)
 
-				// To get the link text
-				tagText, shouldReturn := parseNextTextToken()
-				if shouldReturn {
-					return
+			case "script":
+				nextToken() // To get the inline script
+				if tokenType == html.TextToken {
+					report.detectAuthScript(tkn.Data)
 				}
 
-				report.parseLink(&linkTk, tagText)
+			}
 
-			case "input":
-				report.parseInputTag(&tkn)
+		case html.EndTagToken:
+			switch tknData {
+
+			case "h1", "h2", "h3", "h4", "h5", "h6", "a", "button":
+				report.closeTextElement(tknData)
+
+			case "form":
+				// A submit button left open belongs to the form it was in
+				report.closeTextElement("button")
+				report.closeForm()
+
+			case "label":
+				report.closeLabel()
 
 			}
 
+		case html.TextToken:
+			report.parseText(tkn.Data)
+			report.parseElementText(tkn.Data)
+
 		default:
 			switch tknData {
 
 			case "input":
 				report.parseInputTag(&tkn)
 
+			case "meta":
+				report.parseMeta(&tkn)
+
 			}
 
 		}
 	}
 }
 
+// openTextElement starts a heading, link or button, which is parsed once its text is found.
+// The text may be nested in other tags, such as <a><span>Home</span></a>
+func (report *InspectReport) openTextElement(tag string, tkn html.Token) {
+	// The same element can't be nested, so one left open ends here
+	report.closeTextElement(tag)
+
+	report.textElements = append(report.textElements, &textElement{tag: tag, tkn: tkn})
+}
+
+// parseElementText parses the open headings, links and buttons with their first text
+func (report *InspectReport) parseElementText(text string) {
+	elementText := removeHTMLEmptySpace(text)
+	if len(elementText) == 0 {
+		return
+	}
+
+	for _, element := range report.textElements {
+		report.parseTextElement(element, elementText)
+	}
+	report.textElements = nil
+}
+
+// closeTextElement parses an open heading, link or button that ends without text, such as an icon-only button
+func (report *InspectReport) closeTextElement(tag string) {
+	for i, element := range report.textElements {
+		if element.tag == tag {
+			report.textElements = append(report.textElements[:i:i], report.textElements[i+1:]...)
+			report.parseTextElement(element, "")
+			return
+		}
+	}
+}
+
+// closeTextElements parses all open headings, links and buttons without text
+func (report *InspectReport) closeTextElements() {
+	for len(report.textElements) > 0 {
+		report.closeTextElement(report.textElements[0].tag)
+	}
+}
+
+func (report *InspectReport) parseTextElement(element *textElement, text string) {
+	switch element.tag {
+	case "a":
+		report.parseLink(&element.tkn, text)
+		report.detectAuthText(text)
+
+	case "button":
+		report.parseButton(&element.tkn, text)
+		report.detectAuthText(text)
+
+	default:
+		if len(text) > 0 {
+			report.Headings[element.tag] = append(report.Headings[element.tag], text)
+		}
+	}
+}
+
 func (report *InspectReport) parseInputTag(tkn *html.Token) {
 
+	report.parseFormField(tkn)
+	report.detectAuthInput(tkn)
+
 	// check if a password input
 	for _, attr := range tkn.Attr {
 		if strings.ToLower(attr.Key) == "type" && strings.ToLower(attr.Val) == "password" {
@@ -254,7 +542,7 @@ func (report *InspectReport) parseInputTag(tkn *html.Token) {
 }
 
 func (report *InspectReport) parseLink(ATag *html.Token, linkText string) {
-	link := InspectedLink{Text: linkText, StatusCode: 0}
+	link := InspectedLink{Text: linkText, StatusCode: 0, Section: report.currentLandmark()}
 	var linkURL string
 
 	// Get the href attribute
@@ -327,79 +615,214 @@ func (report *InspectReport) parseLink(ATag *html.Token, linkText string) {
 	report.Links = append(report.Links, &link)
 
 	// Analyse the link if it's not a special action link
-	// and RequestContext is not nil
-	if shouldAnalyse && report.RequestContext != nil {
+	if shouldAnalyse {
+		report.scheduleLinkAnalysis(&link)
+	}
+}
+
+// scheduleLinkAnalysis adds the link to the links to be analysed if RequestContext is not nil.
+// Link analysis starts when startLinkAnalysis is called.
+func (report *InspectReport) scheduleLinkAnalysis(link *InspectedLink) {
+	if report.RequestContext == nil || !report.Options.shouldAnalyseLinkType(link.Type) {
+		return
+	}
+
+	report.pendingLinks = append(report.pendingLinks, link)
+}
+
+// startLinkAnalysis starts analysing the scheduled links in the background, in the order of Options.LinkOrder
+func (report *InspectReport) startLinkAnalysis() {
+	pendingLinks := report.pendingLinks
+	report.pendingLinks = nil
+
+	// Resumed inspections already have their sample
+	if report.Options.SampleSize > 0 && len(pendingLinks) > report.Options.SampleSize && report.LinkSample == nil {
+		pendingLinks = report.sampleLinks(pendingLinks, report.Options.SampleSize)
+	}
+
+	pendingLinks = orderLinks(pendingLinks, report.Options.LinkOrder)
+
+	// The links left out by MaxLinks are the least representative, as the links are already ordered
+	if report.Options.MaxLinks > 0 && len(pendingLinks) > report.Options.MaxLinks {
+		pendingLinks = pendingLinks[:report.Options.MaxLinks]
+	}
+
+	for _, link := range pendingLinks {
+		link.queued = true
+
+		// Don't start new link checks near the deadline
+		if deadline, hasDeadline := (*report.RequestContext).Deadline(); hasDeadline && time.Until(deadline) < LinkCheckSchedulingMargin {
+			link.Unfinished = true
+			continue
+		}
+
 		// Add the link to the wait group
 		report.LinkAnalyticWG.Add(1)
-		go report.analyseLink(linkURL, &link)
+		linkAnalysisScheduler.enqueue(report, link)
 	}
 }
 
+// analyseLink sends a request to the link and records its status. It is run by linkAnalysisScheduler
 func (report *InspectReport) analyseLink(inputURL string, link *InspectedLink) {
 
-	// Remove the link from the wait group
-	defer report.LinkAnalyticWG.Done()
-
-	// This blocks if the semaphore is full
-	concurrentLinkAnalysersSemaphore <- struct{}{}
-
-	defer func() {
-		// Release the semaphore
-		<-concurrentLinkAnalysersSemaphore
-	}()
+	requestContext := *report.RequestContext
+	if report.Options.Timeout > 0 {
+		var requestContextCancel context.CancelFunc
+		requestContext, requestContextCancel = context.WithTimeout(requestContext, report.Options.Timeout)
+		defer requestContextCancel()
+	}
 
 	// Get the webpage for the link within the context of the request
-	outgoingReq, outgoingReqErr := http.NewRequestWithContext(*report.RequestContext, http.MethodGet, inputURL, nil)
+	outgoingReq, outgoingReqErr := http.NewRequestWithContext(requestContext, http.MethodGet, inputURL, nil)
 
 	if outgoingReqErr != nil || outgoingReq == nil {
-		link.StatusCode = http.StatusInternalServerError
-		link.Type = "error"
+		report.recordLinkResult(link, "error", http.StatusInternalServerError)
 		return
 	}
 
-	// Disguise the user agent as a google chrome browser running on linux
-	outgoingReq.Header.Set(`User-Agent`, `Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/100.0.4896.127 Safari/537.36`)
-	outgoingReq.Header.Set(`sec-ch-ua`, `" Not A;Brand";v="99", "Chromium";v="100", "Google Chrome";v="100"`)
-	outgoingReq.Header.Set(`sec-ch-ua-mobile`, `?0`)
-	outgoingReq.Header.Set(`sec-ch-ua-platform`, `"Linux"`)
-	outgoingReq.Header.Set(`Sec-Fetch-Dest`, `document`)
-	outgoingReq.Header.Set(`Sec-Fetch-Mode`, `navigate`)
-	outgoingReq.Header.Set(`Sec-Fetch-Site`, `same-origin`)
-	outgoingReq.Header.Set(`Sec-Fetch-User`, `?1`)
+	for headerKey, headerValues := range report.Options.headers() {
+		for _, headerValue := range headerValues {
+			outgoingReq.Header.Add(headerKey, headerValue)
+		}
+	}
+
+	// Links to the same website may be behind the same login as the web page
+	if report.Options.sendsCredentialsTo(report.ParsedURL, inputURL) {
+		report.Options.addCredentials(outgoingReq)
+	}
+
+	httpResp, httpErr := report.HTTPClient.Do(outgoingReq)
+
+	if httpResp != nil {
+		httpResp.Body.Close()
+	}
+
+	if report.Options.CheckLinkTLS {
+		report.recordLinkTLS(outgoingReq.URL, httpResp, httpErr)
+	}
+
+	var blockedErr *BlockedAddressError
 
-	httpResp, httpErr := http.DefaultClient.Do(outgoingReq)
+	linkType := link.Type
+	statusCode := 0
 
 	// If there was an error getting the webpage, return an error
 	if httpErr != nil {
-		if httpResp != nil {
-			link.StatusCode = httpResp.StatusCode
+		if (*report.RequestContext).Err() != nil {
+			// The deadline was reached while the link was being analysed
+			report.markLinkUnfinished(link)
+			return
+
+		} else if errors.As(httpErr, &blockedErr) {
+			// The link points to a private or reserved network
+			linkType = "blocked"
+			statusCode = http.StatusForbidden
+
+		} else if httpResp != nil {
+			statusCode = httpResp.StatusCode
 		} else {
-			link.StatusCode = http.StatusRequestTimeout
+			statusCode = http.StatusRequestTimeout
 		}
 
 	} else {
-		link.StatusCode = httpResp.StatusCode
+		statusCode = httpResp.StatusCode
 
 	}
 
 	// some websites like linkedin, do not allow bots to access their pages
-	if link.StatusCode > 600 {
-		link.Type = "unscannable"
-		link.StatusCode = http.StatusOK
+	if statusCode > 600 {
+		linkType = "unscannable"
+		statusCode = http.StatusOK
+	}
+
+	report.recordLinkResult(link, linkType, statusCode)
+}
+
+// recordLinkResult sets the type and status code of an analysed link
+func (report *InspectReport) recordLinkResult(link *InspectedLink, linkType string, statusCode int) {
+	defer report.lockLinks()()
+
+	link.Type = linkType
+	link.StatusCode = statusCode
+}
+
+// markLinkUnfinished marks a link that could not be analysed before the deadline
+func (report *InspectReport) markLinkUnfinished(link *InspectedLink) {
+	defer report.lockLinks()()
+
+	link.Unfinished = true
+}
+
+// lockLinks locks the results of Links for writing, and returns the function that unlocks them.
+// Reports not created by newInspectReport have no link checks running, so they are not locked
+func (report *InspectReport) lockLinks() func() {
+	if report.linksLock == nil {
+		return func() {}
+	}
+
+	report.linksLock.Lock()
+	return report.linksLock.Unlock
+}
+
+// readLockLinks locks the results of Links for reading, and returns the function that unlocks them
+func (report *InspectReport) readLockLinks() func() {
+	if report.linksLock == nil {
+		return func() {}
+	}
+
+	report.linksLock.RLock()
+	return report.linksLock.RUnlock
+}
+
+// MarshalJSON encodes the report, without racing with the link checks still in progress
+func (report *InspectReport) MarshalJSON() ([]byte, error) {
+	// Without the methods of InspectReport, so this is not called again
+	type reportJSON InspectReport
+
+	defer report.readLockLinks()()
+
+	return json.Marshal((*reportJSON)(report))
+}
+
+// FinishLinkAnalysis cancels any link analysis still in progress,
+// and marks the queued links that could not be analysed before the deadline as unfinished.
+// Call it before returning the final report.
+func (report *InspectReport) FinishLinkAnalysis() {
+	if report.RequestContextCancel != nil {
+		report.RequestContextCancel()
+	}
+
+	// Link analytics were never requested, so there is nothing to finish
+	if report.RequestContext == nil {
+		return
 	}
 
+	// Link checks that were canceled may still be recording their results
+	defer report.lockLinks()()
+
+	for _, lnk := range report.Links {
+		if lnk.StatusCode == 0 && lnk.queued {
+			lnk.Unfinished = true
+		}
+	}
 }
 
 func (report *InspectReport) CountLinks() {
+	defer report.lockLinks()()
+
 	accessible := 0
 	inaccessible := 0
 	notAnalysed := 0
+	unfinished := 0
 
 	for _, lnk := range report.Links {
 		if lnk.StatusCode == 0 {
-			if lnk.Type == "external" || lnk.Type == "absolute" || lnk.Type == "relative" {
+			if isAnalysableLinkType(lnk.Type) {
 				notAnalysed++
 			}
+			if lnk.Unfinished {
+				unfinished++
+			}
 		} else if lnk.StatusCode < 400 {
 			accessible++
 		} else {
@@ -410,6 +833,22 @@ func (report *InspectReport) CountLinks() {
 	report.AccessibleLinkCount = accessible
 	report.InaccessibleLinkCount = inaccessible
 	report.NotAnalysedLinkCount = notAnalysed
+	report.UnfinishedLinkCount = unfinished
+
+	if report.previousLinks != nil {
+		report.AccessibleLinkCount += report.previousLinks.Accessible
+		report.InaccessibleLinkCount += report.previousLinks.Inaccessible
+		report.NotAnalysedLinkCount += report.previousLinks.NotAnalysed
+	}
+
+	if report.LinkSample != nil {
+		report.estimateLinkSample()
+	}
+}
+
+// Links of these types are analysed by sending a request to them
+func isAnalysableLinkType(linkType string) bool {
+	return linkType == "external" || linkType == "absolute" || linkType == "relative"
 }
 
 // Remove HTML empty spaces
@@ -2,7 +2,10 @@ package inspector
 
 import (
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
 const testArchiveURL = "https://inspect-go.vercel.app/tests/"
@@ -21,6 +24,9 @@ func testInspectURL(urlPair urlPair) *InspectReport {
 	return inspectURLResponse(urlPair.original, httpResp, httpErr, nil)
 }
 
+// Test servers listen on loopback addresses, which are blocked by default
+var loopbackNetworks = mustParseNetworks("127.0.0.0/8,::1")
+
 type urlPair struct {
 	archived string
 	original string
@@ -153,3 +159,68 @@ func TestInspectURLLinks(t *testing.T) {
 		}
 	}
 }
+
+func TestInspectURLUnfinishedLinks(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/slow":
+			select {
+			case <-time.After(5 * time.Second):
+			case <-req.Context().Done():
+			}
+		case "/fast":
+			wr.Write([]byte("fast"))
+		default:
+			wr.Write([]byte(`<html><body><a href="/fast">fast</a><a href="/slow">slow</a></body></html>`))
+		}
+	}))
+	defer server.Close()
+
+	deadline := time.Now().Add(time.Second)
+	report := InspectURLWithOptions(server.URL, &Options{LinkAnalyticsDeadline: &deadline, AllowedNetworks: loopbackNetworks})
+	report.LinkAnalyticWG.Wait()
+	report.FinishLinkAnalysis()
+	report.CountLinks()
+
+	if report.AccessibleLinkCount != 1 {
+		t.Errorf("returned %d accessible links, expected 1", report.AccessibleLinkCount)
+	}
+	if report.UnfinishedLinkCount != 1 {
+		t.Errorf("returned %d unfinished links, expected 1", report.UnfinishedLinkCount)
+	}
+	if report.InaccessibleLinkCount != 0 {
+		t.Errorf("returned %d inaccessible links, expected 0", report.InaccessibleLinkCount)
+	}
+}
+
+func TestInspectHTML(t *testing.T) {
+
+	// Only the links are served. The document itself is inspected before it is deployed
+	server := httptest.NewServer(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/about" {
+			http.NotFound(wr, req)
+		}
+	}))
+	defer server.Close()
+
+	document := `<!DOCTYPE html><html><head><title>Build output</title></head>
+		<body><h1>Welcome</h1><a href="/about">About</a><a href="missing">Missing</a></body></html>`
+
+	deadline := time.Now().Add(5 * time.Second)
+	report := InspectHTML(strings.NewReader(document), server.URL+"/docs/", &Options{LinkAnalyticsDeadline: &deadline, AllowedNetworks: loopbackNetworks})
+	report.LinkAnalyticWG.Wait()
+	report.FinishLinkAnalysis()
+	report.CountLinks()
+
+	if report.StatusCode != 200 || report.PageTitle != "Build output" || report.HTMLVersion != "HTML 5" {
+		t.Errorf("returned status %d, title %q and version %q", report.StatusCode, report.PageTitle, report.HTMLVersion)
+	}
+	if report.AccessibleLinkCount != 1 || report.InaccessibleLinkCount != 1 {
+		t.Errorf("returned %d accessible and %d inaccessible links, expected 1 and 1", report.AccessibleLinkCount, report.InaccessibleLinkCount)
+	}
+
+	if report := InspectHTML(strings.NewReader(document), "ftp://example.com", nil); report.Error == nil || report.Error.Code != ErrorCodeInvalidURL {
+		t.Errorf("invalid base URL returned error %+v, expected %s", report.Error, ErrorCodeInvalidURL)
+	}
+}
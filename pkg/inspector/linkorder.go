@@ -0,0 +1,139 @@
+package inspector
+
+import (
+	"math/rand"
+	"net/url"
+	"sort"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// Orders of link checks. When the deadline is short, only the first links get checked,
+// so the order decides how representative the report is.
+const (
+	// Links are checked in the order they appear in the document
+	LinkOrderDocument = "document"
+
+	// Links to the inspected website are checked before external links
+	LinkOrderInternalFirst = "internal-first"
+
+	// One link of each host is checked before the second link of any host
+	LinkOrderUniqueHosts = "unique-hosts"
+
+	// Links in the main content are checked before links in headers, navigation bars and footers
+	LinkOrderMainContent = "main-content"
+
+	// Links are checked in a random order, which is a fair sample of the page
+	LinkOrderRandom = "random"
+)
+
+var LinkOrders = []string{LinkOrderDocument, LinkOrderInternalFirst, LinkOrderUniqueHosts, LinkOrderMainContent, LinkOrderRandom}
+
+// Landmark elements recorded as InspectedLink.Section
+var landmarkTags = map[string]bool{
+	"main":    true,
+	"article": true,
+	"header":  true,
+	"nav":     true,
+	"footer":  true,
+	"aside":   true,
+}
+
+// Rank of each section in LinkOrderMainContent. Links outside any landmark rank between the main content and the rest
+var sectionRanks = map[string]int{
+	"main":    0,
+	"article": 0,
+	"":        1,
+	"aside":   2,
+	"header":  3,
+	"nav":     3,
+	"footer":  3,
+}
+
+// trackLandmark keeps openLandmarks up to date with every token read by ParseTokens
+func (report *InspectReport) trackLandmark(tokenType html.TokenType, tkn *html.Token) {
+	if !landmarkTags[tkn.Data] {
+		return
+	}
+
+	switch tokenType {
+	case html.StartTagToken:
+		report.openLandmarks = append(report.openLandmarks, tkn.Data)
+
+	case html.EndTagToken:
+		// Close the innermost landmark of this kind, ignoring end tags that were never opened
+		for i := len(report.openLandmarks) - 1; i >= 0; i-- {
+			if report.openLandmarks[i] == tkn.Data {
+				report.openLandmarks = report.openLandmarks[:i]
+				break
+			}
+		}
+	}
+}
+
+// currentLandmark returns the innermost landmark element enclosing the current token, or an empty string
+func (report *InspectReport) currentLandmark() string {
+	if len(report.openLandmarks) == 0 {
+		return ""
+	}
+	return report.openLandmarks[len(report.openLandmarks)-1]
+}
+
+// orderLinks returns the links in the given order. Unknown orders keep the document order
+func orderLinks(links []*InspectedLink, linkOrder string) []*InspectedLink {
+	ordered := make([]*InspectedLink, len(links))
+	copy(ordered, links)
+
+	switch linkOrder {
+	case LinkOrderInternalFirst:
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return ordered[i].Type != "external" && ordered[j].Type == "external"
+		})
+
+	case LinkOrderMainContent:
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return sectionRanks[ordered[i].Section] < sectionRanks[ordered[j].Section]
+		})
+
+	case LinkOrderUniqueHosts:
+		ordered = interleaveHosts(ordered)
+
+	case LinkOrderRandom:
+		random := rand.New(rand.NewSource(time.Now().UnixNano()))
+		random.Shuffle(len(ordered), func(i, j int) {
+			ordered[i], ordered[j] = ordered[j], ordered[i]
+		})
+	}
+
+	return ordered
+}
+
+// interleaveHosts takes the first link of each host, then the second link of each host, and so on
+func interleaveHosts(links []*InspectedLink) []*InspectedLink {
+	hosts := []string{}
+	linksByHost := map[string][]*InspectedLink{}
+
+	for _, link := range links {
+		host := ""
+		if parsedURL, parseErr := url.Parse(link.URL); parseErr == nil {
+			host = parsedURL.Host
+		}
+
+		if _, seen := linksByHost[host]; !seen {
+			hosts = append(hosts, host)
+		}
+		linksByHost[host] = append(linksByHost[host], link)
+	}
+
+	interleaved := make([]*InspectedLink, 0, len(links))
+	for round := 0; len(interleaved) < len(links); round++ {
+		for _, host := range hosts {
+			if round < len(linksByHost[host]) {
+				interleaved = append(interleaved, linksByHost[host][round])
+			}
+		}
+	}
+
+	return interleaved
+}
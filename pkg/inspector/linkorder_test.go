@@ -0,0 +1,79 @@
+package inspector
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestLinkSections(t *testing.T) {
+	page := `<body>
+		<header><nav><a href="/home">Home</a></nav><a href="/logo">Logo</a></header>
+		<main><article><header><a href="/author">Author</a></header><a href="/read-more"><span>Read more</span></a></article><a href="/related">Related</a></main>
+		<a href="/loose">Loose</a>
+		<footer><a href="/contact">Contact</a></footer>
+	</body>`
+
+	report := inspectURLResponse("https://example.com", &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(page))}, nil, nil)
+
+	expectedSections := map[string]string{
+		"Home":      "nav",
+		"Logo":      "header",
+		"Author":    "header",
+		"Read more": "article",
+		"Related":   "main",
+		"Loose":     "",
+		"Contact":   "footer",
+	}
+
+	for _, lnk := range report.Links {
+		if lnk.Section != expectedSections[lnk.Text] {
+			t.Errorf("link %s is in section %q, expected %q", lnk.Text, lnk.Section, expectedSections[lnk.Text])
+		}
+	}
+}
+
+func TestOrderLinks(t *testing.T) {
+	links := []*InspectedLink{
+		{URL: "https://a.com/1", Type: "external", Section: "nav"},
+		{URL: "https://a.com/2", Type: "external", Section: "main"},
+		{URL: "https://example.com/3", Type: "absolute", Section: "footer"},
+		{URL: "https://b.com/4", Type: "external", Section: ""},
+		{URL: "https://example.com/5", Type: "relative", Section: "article"},
+	}
+
+	expectedOrders := map[string][]string{
+		LinkOrderDocument:      {"/1", "/2", "/3", "/4", "/5"},
+		LinkOrderInternalFirst: {"/3", "/5", "/1", "/2", "/4"},
+		LinkOrderUniqueHosts:   {"/1", "/3", "/4", "/2", "/5"},
+		LinkOrderMainContent:   {"/2", "/5", "/4", "/1", "/3"},
+	}
+
+	for linkOrder, expectedOrder := range expectedOrders {
+		ordered := orderLinks(links, linkOrder)
+		for i, lnk := range ordered {
+			if !strings.HasSuffix(lnk.URL, expectedOrder[i]) {
+				t.Errorf("order %s put %s at position %d, expected %s", linkOrder, lnk.URL, i, expectedOrder[i])
+			}
+		}
+	}
+
+	if randomOrder := orderLinks(links, LinkOrderRandom); len(randomOrder) != len(links) {
+		t.Errorf("random order returned %d links, expected %d", len(randomOrder), len(links))
+	}
+}
+
+func TestParseTokensWithoutOptions(t *testing.T) {
+	pageURL, _ := url.Parse("https://example.com")
+	report := &InspectReport{ParsedURL: pageURL, Headings: map[string][]string{}}
+
+	report.ParseTokens(html.NewTokenizer(strings.NewReader(`<h1>Home</h1><nav><a href="/about">About</a></nav>`)))
+
+	if len(report.Links) != 1 || report.Links[0].Section != "nav" || len(report.Headings["h1"]) != 1 {
+		t.Errorf("returned links %+v and headings %v", report.Links, report.Headings)
+	}
+}
@@ -0,0 +1,207 @@
+package inspector
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// LoginFlow logs in with the login form of a web page before the inspection. See Options.Login
+type LoginFlow struct {
+	// Page with the login form. The first form with a password field is submitted
+	URL string `json:"url"`
+
+	// Values of form fields by name, such as the username and password.
+	// Other fields of the form, such as hidden CSRF tokens, are submitted with the values of the page
+	Fields map[string]string `json:"fields"`
+}
+
+// loginForm is a form with a password field, and the values it would submit
+type loginForm struct {
+	action string
+	method string
+	fields url.Values
+
+	hasPassword bool
+}
+
+// logIn submits the login form of opts.Login, and returns a copy of opts that sends the session cookies.
+// Static credentials are also sent with the login requests to the same website as pageURL
+func (opts *Options) logIn(ctx context.Context, pageURL string) (*Options, *InspectError) {
+	loginURL, _ := NormalizeURL(opts.Login.URL)
+
+	jar, _ := cookiejar.New(nil)
+	loggedInOpts := *opts
+	loggedInOpts.jar = jar
+
+	parsedPageURL, _ := url.Parse(pageURL)
+	client := loggedInOpts.newHTTPClient(parsedPageURL)
+
+	send := func(req *http.Request) (*http.Response, *InspectError) {
+		if parsedPageURL != nil && isSameOrigin(parsedPageURL, req.URL) {
+			opts.addCredentials(req)
+		}
+
+		resp, respErr := client.Do(req)
+		if respErr != nil {
+			loginErr := ClassifyFetchError(respErr)
+			loginErr.Message = "Login failed : " + loginErr.Message
+			return nil, loginErr
+		}
+		return resp, nil
+	}
+
+	// Get the login page
+	loginPageReq, loginPageErr := http.NewRequestWithContext(ctx, http.MethodGet, loginURL, nil)
+	if loginPageErr != nil {
+		return nil, NewInspectError(ErrorCodeLoginFailed, "Login page URL is not valid", false, loginPageErr)
+	}
+
+	loginPageResp, fetchErr := send(loginPageReq)
+	if fetchErr != nil {
+		return nil, fetchErr
+	}
+
+	form := findLoginForm(loginPageResp.Body)
+	loginPageResp.Body.Close()
+
+	if form == nil {
+		return nil, NewInspectError(ErrorCodeLoginFailed, "No form with a password field was found on the login page", false, nil)
+	}
+
+	// Submit the form with the given values, keeping the other fields
+	for fieldName, fieldValue := range opts.Login.Fields {
+		form.fields.Set(fieldName, fieldValue)
+	}
+
+	actionURL, actionErr := loginPageResp.Request.URL.Parse(form.action)
+	if actionErr != nil {
+		return nil, NewInspectError(ErrorCodeLoginFailed, "Login form action is not a valid URL", false, actionErr)
+	}
+
+	var submitReq *http.Request
+	var submitErr error
+
+	if form.method == http.MethodGet {
+		actionURL.RawQuery = form.fields.Encode()
+		submitReq, submitErr = http.NewRequestWithContext(ctx, http.MethodGet, actionURL.String(), nil)
+	} else {
+		submitReq, submitErr = http.NewRequestWithContext(ctx, http.MethodPost, actionURL.String(), strings.NewReader(form.fields.Encode()))
+		if submitErr == nil {
+			submitReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+	if submitErr != nil {
+		return nil, NewInspectError(ErrorCodeLoginFailed, "Login form could not be submitted", false, submitErr)
+	}
+
+	submitResp, fetchErr := send(submitReq)
+	if fetchErr != nil {
+		return nil, fetchErr
+	}
+	defer submitResp.Body.Close()
+
+	if submitResp.StatusCode >= 400 {
+		return nil, NewInspectError(ErrorCodeLoginFailed, "Login form was rejected with status "+submitResp.Status, false, nil)
+	}
+
+	// Most websites show the login form again when the credentials are wrong
+	if findLoginForm(submitResp.Body) != nil {
+		return nil, NewInspectError(ErrorCodeLoginFailed, "Login form was shown again after logging in. The credentials may be wrong", false, nil)
+	}
+
+	return &loggedInOpts, nil
+}
+
+// findLoginForm returns the first form with a password field in the document, or nil if there is none
+func findLoginForm(document io.Reader) *loginForm {
+	tokenizer := html.NewTokenizer(document)
+	var form *loginForm
+
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			break
+		}
+
+		tkn := tokenizer.Token()
+		tagName := strings.ToLower(tkn.Data)
+
+		switch {
+		case tokenType == html.StartTagToken && tagName == "form":
+			form = &loginForm{
+				action: tokenAttribute(&tkn, "action"),
+				method: strings.ToUpper(tokenAttribute(&tkn, "method")),
+				fields: url.Values{},
+			}
+
+		case tokenType == html.EndTagToken && tagName == "form":
+			if form != nil && form.hasPassword {
+				return form
+			}
+			form = nil
+
+		case (tokenType == html.StartTagToken || tokenType == html.SelfClosingTagToken) && tagName == "input" && form != nil:
+			form.addInput(&tkn)
+		}
+	}
+
+	// The form may not be closed
+	if form != nil && form.hasPassword {
+		return form
+	}
+	return nil
+}
+
+// addInput adds the value the input would submit
+func (form *loginForm) addInput(tkn *html.Token) {
+	inputType := strings.ToLower(tokenAttribute(tkn, "type"))
+	inputName := tokenAttribute(tkn, "name")
+
+	if inputType == "password" {
+		form.hasPassword = true
+	}
+
+	if len(inputName) == 0 {
+		return
+	}
+
+	switch inputType {
+	case "submit", "button", "image", "reset", "file":
+		return
+	case "checkbox", "radio":
+		if !tokenHasAttribute(tkn, "checked") {
+			return
+		}
+	}
+
+	inputValue := tokenAttribute(tkn, "value")
+	if len(inputValue) == 0 && (inputType == "checkbox" || inputType == "radio") {
+		inputValue = "on"
+	}
+
+	form.fields.Add(inputName, inputValue)
+}
+
+func tokenAttribute(tkn *html.Token, key string) string {
+	for _, attr := range tkn.Attr {
+		if strings.ToLower(attr.Key) == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+func tokenHasAttribute(tkn *html.Token, key string) bool {
+	for _, attr := range tkn.Attr {
+		if strings.ToLower(attr.Key) == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package inspector
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoginFlow(t *testing.T) {
+
+	leakedCookies := []string{}
+	leakedCookiesLock := sync.Mutex{}
+
+	thirdParty := httptest.NewServer(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		if cookie := req.Header.Get("Cookie"); len(cookie) > 0 {
+			leakedCookiesLock.Lock()
+			leakedCookies = append(leakedCookies, cookie)
+			leakedCookiesLock.Unlock()
+		}
+	}))
+	defer thirdParty.Close()
+
+	loginPage := `<html><body>
+		<form action="/search"><input type="search" name="q"></form>
+		<form method="post" action="/session">
+			<input type="hidden" name="csrf_token" value="csrf-123">
+			<input type="text" name="username">
+			<input type="password" name="password">
+			<input type="checkbox" name="remember" checked>
+			<input type="submit" name="commit" value="Sign in">
+		</form></body></html>`
+
+	server := httptest.NewServer(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		session, _ := req.Cookie("session")
+		isLoggedIn := session != nil && session.Value == "valid"
+
+		switch req.URL.Path {
+		case "/login":
+			http.SetCookie(wr, &http.Cookie{Name: "csrf", Value: "csrf-123", Path: "/"})
+			wr.Write([]byte(loginPage))
+
+		case "/session":
+			csrfCookie, _ := req.Cookie("csrf")
+			req.ParseForm()
+
+			if csrfCookie == nil || req.PostForm.Get("csrf_token") != csrfCookie.Value || req.PostForm.Get("remember") != "on" || len(req.PostForm.Get("commit")) > 0 {
+				wr.WriteHeader(http.StatusForbidden)
+				return
+			}
+			if req.PostForm.Get("username") != "admin" || req.PostForm.Get("password") != "secret" {
+				wr.Write([]byte(loginPage))
+				return
+			}
+
+			http.SetCookie(wr, &http.Cookie{Name: "session", Value: "valid", Path: "/"})
+			http.Redirect(wr, req, "/dashboard", http.StatusSeeOther)
+
+		case "/dashboard":
+			if !isLoggedIn {
+				http.Redirect(wr, req, "/login", http.StatusFound)
+				return
+			}
+			wr.Write([]byte(`<title>Dashboard</title><a href="/settings">settings</a><a href="` + thirdParty.URL + `/">external</a>`))
+
+		case "/settings":
+			if !isLoggedIn {
+				wr.WriteHeader(http.StatusUnauthorized)
+			}
+		}
+	}))
+	defer server.Close()
+
+	inspect := func(password string, credentialPolicy string) *InspectReport {
+		deadline := time.Now().Add(5 * time.Second)
+		report := InspectURLWithOptions(server.URL+"/dashboard", &Options{
+			LinkAnalyticsDeadline: &deadline,
+			AllowedNetworks:       loopbackNetworks,
+			CredentialPolicy:      credentialPolicy,
+			Login: &LoginFlow{
+				URL:    server.URL + "/login",
+				Fields: map[string]string{"username": "admin", "password": password},
+			},
+		})
+		report.LinkAnalyticWG.Wait()
+		report.CountLinks()
+		return report
+	}
+
+	report := inspect("secret", CredentialPolicySameHost)
+	if report.Error != nil || report.PageTitle != "Dashboard" {
+		t.Fatalf("logged in inspection returned title %q and error %+v", report.PageTitle, report.Error)
+	}
+	if report.AccessibleLinkCount != 2 {
+		t.Errorf("returned %d accessible links, expected 2 with the session", report.AccessibleLinkCount)
+	}
+
+	report = inspect("secret", CredentialPolicyPage)
+	if report.PageTitle != "Dashboard" || report.InaccessibleLinkCount != 1 {
+		t.Errorf("returned title %q and %d inaccessible links, expected the settings link to be inaccessible without the session", report.PageTitle, report.InaccessibleLinkCount)
+	}
+
+	report = inspect("wrong", CredentialPolicyPage)
+	if report.Error == nil || report.Error.Code != ErrorCodeLoginFailed {
+		t.Errorf("wrong password returned error %+v, expected %s", report.Error, ErrorCodeLoginFailed)
+	}
+
+	if len(leakedCookies) > 0 {
+		t.Errorf("session cookies were sent to a third party : %v", leakedCookies)
+	}
+
+	if form := findLoginForm(strings.NewReader(`<form><input name="q"></form>`)); form != nil {
+		t.Errorf("form without a password field was found as a login form")
+	}
+}
@@ -0,0 +1,139 @@
+package inspector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Larger HTML responses are not inspected by DevMiddleware
+var MaxDevMiddlewareDocumentSize = 5 << 20
+
+// ProblemHandler receives the problems of a page inspected by DevMiddleware
+type ProblemHandler func(req *http.Request, report *InspectReport, problems []Problem)
+
+// LogProblems is the default ProblemHandler. It logs every problem of the page
+func LogProblems(req *http.Request, report *InspectReport, problems []Problem) {
+	for _, problem := range problems {
+		log.Println("inspector : " + req.URL.Path + " : " + problem.Code + " : " + problem.Message)
+	}
+}
+
+// DevMiddleware inspects the HTML pages served by next, for use in local development servers.
+// Responses are passed through unchanged. Once a page is sent, it is inspected in the background,
+// checking its internal links against next without the network, and onProblems is called with its problems, if any.
+//
+// Only successful responses to GET requests are inspected. Upgrade requests, such as WebSockets and live reload, are passed through.
+// Pass nil for onProblems to log the problems with LogProblems.
+func DevMiddleware(next http.Handler, opts *Options, onProblems ProblemHandler) http.Handler {
+	if onProblems == nil {
+		onProblems = LogProblems
+	}
+
+	return http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet || len(req.Header.Get("Upgrade")) > 0 {
+			next.ServeHTTP(wr, req)
+			return
+		}
+
+		tee := &teeResponseWriter{ResponseWriter: wr, statusCode: http.StatusOK}
+		next.ServeHTTP(tee, req)
+
+		if !tee.isHTML || tee.overflowed || tee.hijacked || tee.statusCode != http.StatusOK {
+			return
+		}
+
+		document := tee.document.Bytes()
+
+		// The server may reuse the request once ServeHTTP returns, so the background inspection gets its own copy.
+		// Its context is not canceled with the request, and its body was already read by next
+		inspectedReq := req.Clone(context.Background())
+		inspectedReq.Body = http.NoBody
+
+		go func() {
+			report := InspectHTML(bytes.NewReader(document), HandlerBaseURL+inspectedReq.URL.Path, handlerOptions(next, opts))
+			report.LinkAnalyticWG.Wait()
+			report.FinishLinkAnalysis()
+			report.CountLinks()
+
+			if problems := report.Problems(); len(problems) > 0 {
+				onProblems(inspectedReq, report, problems)
+			}
+		}()
+	})
+}
+
+// teeResponseWriter passes the response through, keeping a copy of HTML documents
+type teeResponseWriter struct {
+	http.ResponseWriter
+
+	statusCode  int
+	wroteHeader bool
+
+	sniffed    bool
+	isHTML     bool
+	overflowed bool
+	hijacked   bool
+	document   bytes.Buffer
+}
+
+func (tee *teeResponseWriter) WriteHeader(statusCode int) {
+	if !tee.wroteHeader {
+		tee.wroteHeader = true
+		tee.statusCode = statusCode
+	}
+	tee.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (tee *teeResponseWriter) Write(data []byte) (int, error) {
+	tee.wroteHeader = true
+
+	if !tee.sniffed {
+		tee.sniffed = true
+
+		// net/http sniffs the content type of the first write the same way
+		contentType := tee.Header().Get("Content-Type")
+		if len(contentType) == 0 {
+			contentType = http.DetectContentType(data)
+		}
+		tee.isHTML = strings.HasPrefix(strings.ToLower(contentType), "text/html")
+	}
+
+	if tee.isHTML && !tee.overflowed {
+		if tee.document.Len()+len(data) > MaxDevMiddlewareDocumentSize {
+			tee.overflowed = true
+			tee.document.Reset()
+		} else {
+			tee.document.Write(data)
+		}
+	}
+
+	return tee.ResponseWriter.Write(data)
+}
+
+// Flush keeps streaming responses working
+func (tee *teeResponseWriter) Flush() {
+	if flusher, isFlusher := tee.ResponseWriter.(http.Flusher); isFlusher {
+		flusher.Flush()
+	}
+}
+
+// Hijack lets handlers take over the connection. Hijacked responses are not inspected
+func (tee *teeResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, isHijacker := tee.ResponseWriter.(http.Hijacker)
+	if !isHijacker {
+		return nil, nil, http.ErrNotSupported
+	}
+
+	tee.hijacked = true
+	return hijacker.Hijack()
+}
+
+// Unwrap gives http.ResponseController access to the original writer
+func (tee *teeResponseWriter) Unwrap() http.ResponseWriter {
+	return tee.ResponseWriter
+}
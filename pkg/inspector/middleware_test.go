@@ -0,0 +1,112 @@
+package inspector
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDevMiddleware(t *testing.T) {
+	page := `<!DOCTYPE html><html><body><h2>Welcome</h2><h4>News</h4><a href="/about">About</a><a href="/missing">Missing</a></body></html>`
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/about", func(wr http.ResponseWriter, req *http.Request) {
+		wr.Write([]byte("about"))
+	})
+	mux.HandleFunc("/", func(wr http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/" {
+			http.NotFound(wr, req)
+			return
+		}
+		wr.Write([]byte(page))
+	})
+
+	reportedProblems := make(chan []Problem, 1)
+	reportedPath := ""
+	handler := DevMiddleware(mux, nil, func(req *http.Request, report *InspectReport, problems []Problem) {
+		reportedPath = req.URL.Path
+		reportedProblems <- problems
+	})
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	handler.ServeHTTP(recorder, req)
+
+	// Servers may reuse the request once ServeHTTP returns, which must not affect the background inspection
+	req.URL.Path = "/reused"
+
+	if body, _ := io.ReadAll(recorder.Result().Body); string(body) != page {
+		t.Errorf("middleware changed the response to %q", body)
+	}
+
+	select {
+	case problems := <-reportedProblems:
+		if reportedPath != "/" {
+			t.Errorf("reported the problems of %s, expected /", reportedPath)
+		}
+
+		problemCounts := map[string]int{}
+		for _, problem := range problems {
+			problemCounts[problem.Code]++
+		}
+
+		expectedCounts := map[string]int{ProblemMissingTitle: 1, ProblemHeadingOrder: 2, ProblemBrokenInternalLink: 1}
+		for code, expectedCount := range expectedCounts {
+			if problemCounts[code] != expectedCount {
+				t.Errorf("reported %d %s problems, expected %d : %+v", problemCounts[code], code, expectedCount, problems)
+			}
+		}
+
+	case <-time.After(5 * time.Second):
+		t.Fatal("no problems were reported")
+	}
+}
+
+func TestDevMiddlewareHijack(t *testing.T) {
+	handler := DevMiddleware(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		hijacker, isHijacker := wr.(http.Hijacker)
+		if !isHijacker {
+			http.Error(wr, "not a hijacker", http.StatusInternalServerError)
+			return
+		}
+
+		conn, _, hijackErr := hijacker.Hijack()
+		if hijackErr != nil {
+			http.Error(wr, hijackErr.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer conn.Close()
+
+		conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Type: text/html\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked"))
+	}), nil, func(req *http.Request, report *InspectReport, problems []Problem) {
+		t.Errorf("inspected the hijacked response of %s", req.URL.Path)
+	})
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	// Upgrade requests are passed through, and handlers can hijack other requests too
+	for _, upgrade := range []string{"websocket", ""} {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/live-reload", nil)
+		if len(upgrade) > 0 {
+			req.Header.Set("Connection", "Upgrade")
+			req.Header.Set("Upgrade", upgrade)
+		}
+
+		resp, respErr := http.DefaultClient.Do(req)
+		if respErr != nil {
+			t.Fatal(respErr)
+		}
+
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "hijacked" {
+			t.Errorf("upgrade %q returned %q, expected the hijacked response", upgrade, body)
+		}
+	}
+
+	// Give a wrongly started inspection the time to report
+	time.Sleep(50 * time.Millisecond)
+}
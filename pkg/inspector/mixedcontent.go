@@ -0,0 +1,136 @@
+package inspector
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Kinds of mixed content. See MixedContent.Kind
+const (
+	// Scripts, iframes, stylesheets, objects and embeds, which browsers block,
+	// and form actions, which browsers warn about before submitting the form
+	MixedContentActive = "active"
+
+	// Images, audio and video, including their preloads. Browsers upgrade them to HTTPS, or block them if that fails
+	MixedContentPassive = "passive"
+
+	// Links to plain HTTP pages, which are not loaded with the page but leave HTTPS when followed
+	MixedContentNavigational = "navigational"
+)
+
+// MixedContent is a resource or link of an HTTPS page that uses plain HTTP
+type MixedContent struct {
+	URL string `json:"url"`
+
+	// Tag and attribute of the URL, such as script src
+	Tag       string `json:"tag"`
+	Attribute string `json:"attribute"`
+
+	// See the MixedContent constants
+	Kind string `json:"kind"`
+}
+
+// mixedContentAttributes are the attributes of a tag that load a resource or link, and their kind of mixed content
+var mixedContentAttributes = map[string]map[string]string{
+	"script": {"src": MixedContentActive},
+	"iframe": {"src": MixedContentActive},
+	"frame":  {"src": MixedContentActive},
+	"form":   {"action": MixedContentActive},
+	"button": {"formaction": MixedContentActive},
+	"object": {"data": MixedContentActive},
+	"embed":  {"src": MixedContentActive},
+	"img":    {"src": MixedContentPassive, "srcset": MixedContentPassive},
+	"source": {"src": MixedContentPassive, "srcset": MixedContentPassive},
+	"video":  {"src": MixedContentPassive, "poster": MixedContentPassive},
+	"audio":  {"src": MixedContentPassive},
+	"track":  {"src": MixedContentPassive},
+	"a":      {"href": MixedContentNavigational},
+	"area":   {"href": MixedContentNavigational},
+}
+
+// linkRelKinds are the kinds of mixed content of <link> tags by their rel. Preloads are classified by preloadKinds
+var linkRelKinds = map[string]string{
+	"stylesheet":    MixedContentActive,
+	"preload":       MixedContentActive,
+	"modulepreload": MixedContentActive,
+	"icon":          MixedContentPassive,
+}
+
+// preloadKinds are the kinds of mixed content of <link rel="preload"> tags by their as attribute.
+// Other destinations, such as script, style and font, are active
+var preloadKinds = map[string]string{
+	"image": MixedContentPassive,
+	"audio": MixedContentPassive,
+	"video": MixedContentPassive,
+	"track": MixedContentPassive,
+}
+
+// detectMixedContent adds the plain HTTP resources and links of a tag of an HTTPS page
+func (report *InspectReport) detectMixedContent(tkn *html.Token) {
+	pageURL := report.pageURL()
+	if pageURL == nil || pageURL.Scheme != "https" {
+		return
+	}
+
+	tagName := strings.ToLower(tkn.Data)
+	attributes := mixedContentAttributes[tagName]
+
+	switch tagName {
+	case "link":
+		for _, rel := range strings.Fields(strings.ToLower(tokenAttribute(tkn, "rel"))) {
+			if kind, loadsResource := linkRelKinds[rel]; loadsResource {
+				if preloadKind, isPassive := preloadKinds[strings.ToLower(strings.TrimSpace(tokenAttribute(tkn, "as")))]; rel == "preload" && isPassive {
+					kind = preloadKind
+				}
+				attributes = map[string]string{"href": kind}
+				break
+			}
+		}
+	case "input":
+		if strings.EqualFold(tokenAttribute(tkn, "type"), "image") {
+			attributes = map[string]string{"src": MixedContentPassive}
+		}
+	}
+
+	for _, attr := range tkn.Attr {
+		attrName := strings.ToLower(attr.Key)
+
+		kind, loadsResource := attributes[attrName]
+		if !loadsResource {
+			continue
+		}
+
+		rawURLs := []string{attr.Val}
+		if attrName == "srcset" {
+			rawURLs = parseSrcset(attr.Val)
+		}
+
+		for _, rawURL := range rawURLs {
+			resolvedURL, resolveErr := pageURL.Parse(strings.TrimSpace(rawURL))
+			if resolveErr != nil || resolvedURL.Scheme != "http" {
+				continue
+			}
+
+			report.MixedContent = append(report.MixedContent, &MixedContent{
+				URL:       resolvedURL.String(),
+				Tag:       tagName,
+				Attribute: attrName,
+				Kind:      kind,
+			})
+		}
+	}
+}
+
+// parseSrcset returns the URLs of a srcset attribute, such as "image.png 1x, image-2x.png 2x"
+func parseSrcset(srcset string) []string {
+	urls := []string{}
+
+	for _, candidate := range strings.Split(srcset, ",") {
+		if fields := strings.Fields(candidate); len(fields) > 0 {
+			urls = append(urls, fields[0])
+		}
+	}
+
+	return urls
+}
@@ -0,0 +1,147 @@
+package inspector
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDetectMixedContent(t *testing.T) {
+	page := `<head>
+		<script src="http://cdn.example.com/app.js"></script>
+		<script src="//cdn.example.com/secure.js"></script>
+		<link rel="stylesheet" href="http://cdn.example.com/style.css">
+		<link rel="canonical" href="http://example.com/">
+		</head><body>
+		<img src="http://images.example.com/a.png" srcset="https://images.example.com/a.png 1x, http://images.example.com/a-2x.png 2x"/>
+		<iframe src="http://video.example.com/embed"></iframe>
+		<form action="http://example.com/subscribe"><input type="email" name="email"></form>
+		<a href="http://example.org/">Old website</a>
+		<a href="/about">About</a>
+		</body>`
+
+	report := inspectURLResponse("https://example.com", &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(page))}, nil, nil)
+
+	expected := []MixedContent{
+		{"http://cdn.example.com/app.js", "script", "src", MixedContentActive},
+		{"http://cdn.example.com/style.css", "link", "href", MixedContentActive},
+		{"http://images.example.com/a.png", "img", "src", MixedContentPassive},
+		{"http://images.example.com/a-2x.png", "img", "srcset", MixedContentPassive},
+		{"http://video.example.com/embed", "iframe", "src", MixedContentActive},
+		{"http://example.com/subscribe", "form", "action", MixedContentActive},
+		{"http://example.org/", "a", "href", MixedContentNavigational},
+	}
+
+	if len(report.MixedContent) != len(expected) {
+		for _, mixedContent := range report.MixedContent {
+			t.Logf("%+v", mixedContent)
+		}
+		t.Fatalf("found %d mixed content, expected %d", len(report.MixedContent), len(expected))
+	}
+	for i, mixedContent := range report.MixedContent {
+		if *mixedContent != expected[i] {
+			t.Errorf("found %+v, expected %+v", mixedContent, expected[i])
+		}
+	}
+
+	activeProblems := 0
+	for _, problem := range report.Problems() {
+		if problem.Code == ProblemMixedContent {
+			activeProblems++
+		}
+	}
+	if activeProblems != 4 {
+		t.Errorf("returned %d mixed content problems, expected 4", activeProblems)
+	}
+
+	// Plain HTTP pages have no mixed content
+	report = inspectURLResponse("http://example.com", &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(page))}, nil, nil)
+	if len(report.MixedContent) != 0 {
+		t.Errorf("found %d mixed content on a plain HTTP page", len(report.MixedContent))
+	}
+}
+
+func TestDetectMixedContentAfterRedirect(t *testing.T) {
+	page := `<script src="/app.js"></script><script src="http://cdn.example.com/app.js"></script>`
+
+	// Relative URLs of a page upgraded to HTTPS by a redirect are not mixed content, but plain HTTP ones are
+	finalRequest, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	report := inspectURLResponse("http://example.com", &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(page)), Request: finalRequest}, nil, nil)
+	if len(report.MixedContent) != 1 || report.MixedContent[0].URL != "http://cdn.example.com/app.js" {
+		t.Errorf("found mixed content %v on a page redirected to HTTPS, expected only http://cdn.example.com/app.js", report.MixedContent)
+	}
+
+	// A page downgraded to plain HTTP by a redirect has no mixed content
+	finalRequest, _ = http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	report = inspectURLResponse("https://example.com", &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(page)), Request: finalRequest}, nil, nil)
+	if len(report.MixedContent) != 0 {
+		t.Errorf("found %d mixed content on a page redirected to plain HTTP", len(report.MixedContent))
+	}
+}
+
+func TestMixedContentPreloadsAndForms(t *testing.T) {
+	page := `<head>
+		<link rel="preload" as="image" href="http://cdn.example.com/hero.png">
+		<link rel="preload" as="script" href="http://cdn.example.com/lib.js">
+		<link rel="preload" href="http://cdn.example.com/unknown">
+		</head><body>
+		<form action="http://example.com/subscribe"><button formaction="http://example.com/unsubscribe">Unsubscribe</button></form>
+		</body>`
+
+	report := inspectURLResponse("https://example.com", &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(page))}, nil, nil)
+
+	expectedKinds := []string{MixedContentPassive, MixedContentActive, MixedContentActive, MixedContentActive, MixedContentActive}
+	if len(report.MixedContent) != len(expectedKinds) {
+		t.Fatalf("found %d mixed content, expected %d", len(report.MixedContent), len(expectedKinds))
+	}
+	for i, mixedContent := range report.MixedContent {
+		if mixedContent.Kind != expectedKinds[i] {
+			t.Errorf("%s %s was classified as %s, expected %s", mixedContent.Tag, mixedContent.URL, mixedContent.Kind, expectedKinds[i])
+		}
+	}
+
+	// Browsers warn about forms instead of blocking them
+	blocked, warned := 0, 0
+	for _, problem := range report.Problems() {
+		if problem.Code != ProblemMixedContent {
+			continue
+		}
+		if strings.Contains(problem.Message, "browsers block") {
+			blocked++
+		} else if strings.Contains(problem.Message, "browsers warn") {
+			warned++
+		}
+	}
+	if blocked != 2 || warned != 2 {
+		t.Errorf("returned %d blocked and %d warned mixed content problems, expected 2 and 2", blocked, warned)
+	}
+}
+
+func TestMixedContentInsideLinksAndHeadings(t *testing.T) {
+	page := `<a href="/"><img src="http://cdn.example.com/logo.png"></a>
+		<h2><a href="/pricing"><script src="http://cdn.example.com/icon.js"></script>Pricing</a></h2>`
+
+	report := inspectURLResponse("https://example.com", &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(page))}, nil, nil)
+
+	expected := []MixedContent{
+		{"http://cdn.example.com/logo.png", "img", "src", MixedContentPassive},
+		{"http://cdn.example.com/icon.js", "script", "src", MixedContentActive},
+	}
+	if len(report.MixedContent) != len(expected) {
+		t.Fatalf("found %d mixed content, expected %d", len(report.MixedContent), len(expected))
+	}
+	for i, mixedContent := range report.MixedContent {
+		if *mixedContent != expected[i] {
+			t.Errorf("found %+v, expected %+v", mixedContent, expected[i])
+		}
+	}
+
+	// The link inside the heading is kept along with the heading
+	if len(report.Links) != 2 || report.Links[1].Text != "Pricing" {
+		t.Errorf("returned links %+v, expected the logo link and Pricing", report.Links)
+	}
+	if len(report.Headings["h2"]) != 1 || report.Headings["h2"][0] != "Pricing" {
+		t.Errorf("returned h2 headings %v, expected Pricing", report.Headings["h2"])
+	}
+}
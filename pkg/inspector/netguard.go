@@ -0,0 +1,136 @@
+package inspector
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Inspections never connect to these networks, unless they are allowed with Options.AllowedNetworks.
+// This covers loopback, private, link-local (including cloud metadata endpoints), shared, multicast and reserved ranges.
+var BlockedNetworks = mustParseNetworks(strings.Join([]string{
+	// IPv4
+	"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8", "169.254.0.0/16", "172.16.0.0/12",
+	"192.0.0.0/24", "192.0.2.0/24", "192.88.99.0/24", "192.168.0.0/16", "198.18.0.0/15",
+	"198.51.100.0/24", "203.0.113.0/24", "224.0.0.0/4", "240.0.0.0/4",
+
+	// IPv6. IPv4-mapped addresses are checked as IPv4
+	"::/128", "::1/128", "64:ff9b::/96", "64:ff9b:1::/48", "100::/64", "2001::/23", "2001:db8::/32",
+	"2002::/16", "fc00::/7", "fe80::/10", "ff00::/8",
+}, ","))
+
+// BlockedAddressError is returned when an inspection tries to connect to a blocked network
+type BlockedAddressError struct {
+	IP net.IP
+}
+
+func (blockedErr *BlockedAddressError) Error() string {
+	return fmt.Sprintf("connecting to %s is not allowed", blockedErr.IP)
+}
+
+// ParseNetworks parses a comma separated list of CIDR ranges and IP addresses, such as "10.1.0.0/16,192.168.1.10"
+func ParseNetworks(networks string) ([]*net.IPNet, error) {
+	parsedNetworks := []*net.IPNet{}
+
+	for _, network := range strings.Split(networks, ",") {
+		network = strings.TrimSpace(network)
+		if len(network) == 0 {
+			continue
+		}
+
+		// A single IP address is a network of its own
+		if !strings.Contains(network, "/") {
+			ip := net.ParseIP(network)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", network)
+			}
+
+			if ip.To4() != nil {
+				network += "/32"
+			} else {
+				network += "/128"
+			}
+		}
+
+		_, parsedNetwork, parseErr := net.ParseCIDR(network)
+		if parseErr != nil {
+			return nil, parseErr
+		}
+
+		parsedNetworks = append(parsedNetworks, parsedNetwork)
+	}
+
+	return parsedNetworks, nil
+}
+
+func mustParseNetworks(networks string) []*net.IPNet {
+	parsedNetworks, parseErr := ParseNetworks(networks)
+	if parseErr != nil {
+		panic(parseErr)
+	}
+	return parsedNetworks
+}
+
+// isBlockedIP reports whether connecting to the IP is not allowed
+func (opts *Options) isBlockedIP(ip net.IP) bool {
+	if ipv4 := ip.To4(); ipv4 != nil {
+		ip = ipv4
+	}
+
+	for _, allowedNetwork := range opts.AllowedNetworks {
+		if allowedNetwork.Contains(ip) {
+			return false
+		}
+	}
+
+	for _, blockedNetwork := range BlockedNetworks {
+		if blockedNetwork.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// guardConnection is called after the host name is resolved, right before connecting.
+// Checking the resolved address here, instead of the host name, defeats DNS rebinding.
+func (opts *Options) guardConnection(network string, address string, _ syscall.RawConn) error {
+	host, _, splitErr := net.SplitHostPort(address)
+	if splitErr != nil {
+		return splitErr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || opts.isBlockedIP(ip) {
+		return &BlockedAddressError{IP: ip}
+	}
+
+	return nil
+}
+
+// newTransport returns an HTTP transport that refuses to connect to blocked networks
+func (opts *Options) newTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   opts.guardConnection,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network string, address string) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, address)
+	}
+
+	// A proxy would connect on our behalf, bypassing the guard
+	transport.Proxy = nil
+
+	if tlsConfig := opts.tlsConfig(); tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return transport
+}
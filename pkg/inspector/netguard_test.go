@@ -0,0 +1,88 @@
+package inspector
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBlockedNetworks(t *testing.T) {
+	opts := &Options{}
+
+	blockedIPs := []string{"127.0.0.1", "10.1.2.3", "172.20.0.1", "192.168.1.1", "169.254.169.254", "100.64.0.1", "0.0.0.0", "::1", "fe80::1", "fd00::1", "::ffff:127.0.0.1"}
+	for _, ip := range blockedIPs {
+		if !opts.isBlockedIP(net.ParseIP(ip)) {
+			t.Errorf("IP %s is not blocked", ip)
+		}
+	}
+
+	allowedIPs := []string{"8.8.8.8", "142.250.72.14", "2606:4700:4700::1111"}
+	for _, ip := range allowedIPs {
+		if opts.isBlockedIP(net.ParseIP(ip)) {
+			t.Errorf("IP %s is blocked", ip)
+		}
+	}
+
+	allowlistedOpts := &Options{AllowedNetworks: mustParseNetworks("10.1.0.0/16, 192.168.1.1")}
+	if allowlistedOpts.isBlockedIP(net.ParseIP("10.1.2.3")) || allowlistedOpts.isBlockedIP(net.ParseIP("192.168.1.1")) {
+		t.Errorf("allowlisted IPs are blocked")
+	}
+	if !allowlistedOpts.isBlockedIP(net.ParseIP("10.2.0.1")) {
+		t.Errorf("IP 10.2.0.1 outside the allowlist is not blocked")
+	}
+
+	if _, parseErr := ParseNetworks("10.0.0.0/8,not-an-ip"); parseErr == nil {
+		t.Errorf("invalid network list was parsed without an error")
+	}
+}
+
+func TestInspectURLBlocksPrivateNetworks(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		wr.Write([]byte("internal"))
+	}))
+	defer server.Close()
+
+	// The host name resolves to a loopback address, which must be blocked after resolution
+	localhostURL := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+
+	for _, blockedURL := range []string{server.URL, localhostURL} {
+		report := InspectURLWithOptions(blockedURL, &Options{})
+		if report.Error == nil || report.Error.Code != ErrorCodeBlockedAddress {
+			t.Errorf("URL %s returned error %v, expected %s", blockedURL, report.Error, ErrorCodeBlockedAddress)
+		}
+	}
+
+	// Links on a public page may not point to private networks either
+	page := `<a href="` + server.URL + `/secret">secret</a>`
+	deadline := time.Now().Add(5 * time.Second)
+	report := inspectURLResponse("https://example.com", &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(page))}, nil, &Options{LinkAnalyticsDeadline: &deadline})
+	report.LinkAnalyticWG.Wait()
+
+	if report.Links[0].Type != "blocked" || report.Links[0].StatusCode != http.StatusForbidden {
+		t.Errorf("link to a private network returned type %s and status code %d, expected blocked and %d", report.Links[0].Type, report.Links[0].StatusCode, http.StatusForbidden)
+	}
+}
+
+func TestSharedTransport(t *testing.T) {
+	opts := &Options{AllowedNetworks: loopbackNetworks}
+	sameOpts := *opts
+
+	if opts.sharedTransport() != sameOpts.sharedTransport() {
+		t.Errorf("options with the same networks returned different transports")
+	}
+	if (&Options{}).sharedTransport() != defaultTransport {
+		t.Errorf("options without networks or TLS configuration did not return the default transport")
+	}
+
+	insecureOpts := sameOpts
+	insecureOpts.InsecureSkipVerify = true
+	if insecureOpts.sharedTransport() == opts.sharedTransport() {
+		t.Errorf("options with a different TLS configuration returned the same transport")
+	}
+
+}
@@ -0,0 +1,317 @@
+package inspector
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options control how a web page is inspected. The zero value fetches the page without analysing links.
+type Options struct {
+	// Maximum time to wait for the link analytics to complete. Pass nil to avoid link analytics.
+	// The web page itself must also be fetched before this deadline.
+	LinkAnalyticsDeadline *time.Time
+
+	// Timeout for fetching the web page and for each link check. Zero means no timeout other than LinkAnalyticsDeadline
+	Timeout time.Duration
+
+	// Maximum number of links to analyse. Links beyond this are listed but not analysed. Zero means no limit
+	MaxLinks int
+
+	// Only check a random sample of this many links, stratified by link type and host, and estimate
+	// the broken link rates of all links from it. See InspectReport.LinkSample. Zero checks all links
+	SampleSize int
+
+	// Only links of these types are analysed (external, absolute, relative). Empty means all of them
+	LinkTypes []string
+
+	// Order of link checks. One of LinkOrders. Defaults to LinkOrderDocument
+	LinkOrder string
+
+	// Headers sent with link checks. One of HeaderProfileBrowser (default), HeaderProfileBot or HeaderProfileNone
+	HeaderProfile string
+
+	// Link checks of this inspection started in each turn, when inspections share link analysers.
+	// An inspection with priority 3 gets three times the link checks of one with priority 1. Defaults to 1
+	Priority int
+
+	// Report redirect responses as they are, instead of following them
+	DisableRedirects bool
+
+	// Also describe the TLS connections and certificates of the hosts of checked links. See InspectReport.LinkTLS
+	CheckLinkTLS bool
+
+	// Networks in BlockedNetworks that may be connected to anyway. Use this for internal deployments.
+	// See ParseNetworks
+	AllowedNetworks []*net.IPNet
+
+	// Credentials sent with the request of the web page, for pages behind a login or basic auth.
+	// Link checks get them only as allowed by CredentialPolicy, and third party websites never get them
+	Cookies   []*http.Cookie
+	Headers   http.Header
+	BasicAuth *BasicAuth
+
+	// Which link checks get the credentials. One of CredentialPolicies. Defaults to CredentialPolicyPage
+	CredentialPolicy string
+
+	// Log in with a login form before fetching the web page. The session cookies are sent like Cookies
+	Login *LoginFlow
+
+	// Certificate authorities trusted besides the system ones, in PEM format. For staging websites with a private CA
+	CABundle []byte
+
+	// Client certificate and private key in PEM format, for websites that require mutual TLS.
+	// Only presented to the website of the page
+	ClientCertificate []byte
+	ClientKey         []byte
+
+	// Skip verifying TLS certificates. Reports of such inspections are marked with InspectReport.InsecureTLS
+	InsecureSkipVerify bool
+
+	// Identifies who runs the inspection, such as the name of an API key.
+	// Continuation tokens can only be resumed with the same client. See ResumeInspection
+	Client string
+
+	// Sends the requests of the inspection instead of the network. See InspectHandler
+	transport http.RoundTripper
+
+	// Session cookies of Login
+	jar http.CookieJar
+}
+
+// Link types that can be analysed, and therefore filtered with Options.LinkTypes
+var AnalysableLinkTypes = []string{"external", "absolute", "relative"}
+
+const (
+	// Disguise link checks as a google chrome browser running on linux. Many websites block other clients
+	HeaderProfileBrowser = "browser"
+
+	// Identify link checks honestly as InspectGo
+	HeaderProfileBot = "bot"
+
+	// Send only the default headers of the Go HTTP client
+	HeaderProfileNone = "none"
+)
+
+var HeaderProfiles = map[string]http.Header{
+	HeaderProfileBrowser: {
+		`User-Agent`:         {`Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/100.0.4896.127 Safari/537.36`},
+		`sec-ch-ua`:          {`" Not A;Brand";v="99", "Chromium";v="100", "Google Chrome";v="100"`},
+		`sec-ch-ua-mobile`:   {`?0`},
+		`sec-ch-ua-platform`: {`"Linux"`},
+		`Sec-Fetch-Dest`:     {`document`},
+		`Sec-Fetch-Mode`:     {`navigate`},
+		`Sec-Fetch-Site`:     {`same-origin`},
+		`Sec-Fetch-User`:     {`?1`},
+	},
+	HeaderProfileBot: {
+		`User-Agent`: {`InspectGo/1.0 (+https://inspect-go.vercel.app)`},
+	},
+	HeaderProfileNone: {},
+}
+
+// NormalizeURL validates a URL entered by a user, adding https:// if the scheme is missing.
+// Malformed URLs, URLs with credentials and schemes other than http and https are rejected.
+func NormalizeURL(inputURL string) (string, *InspectError) {
+
+	inputURL = strings.TrimSpace(inputURL)
+
+	if len(inputURL) == 0 {
+		return "", NewInspectError(ErrorCodeInvalidURL, "URL is empty", false, nil)
+	}
+
+	if !strings.Contains(inputURL, "://") {
+		inputURL = "https://" + inputURL
+	}
+
+	parsedURL, parsedURLErr := url.Parse(inputURL)
+	if parsedURLErr != nil {
+		return "", NewInspectError(ErrorCodeInvalidURL, "URL not in valid format", false, parsedURLErr)
+	}
+
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+		inspectErr := NewInspectError(ErrorCodeInvalidURL, "Only http and https URLs are supported", false, nil)
+		inspectErr.Details = map[string]string{"scheme": parsedURL.Scheme}
+		return "", inspectErr
+	}
+
+	if len(parsedURL.Hostname()) == 0 {
+		return "", NewInspectError(ErrorCodeInvalidURL, "URL has no host name", false, nil)
+	}
+
+	if parsedURL.User != nil {
+		return "", NewInspectError(ErrorCodeInvalidURL, "URLs with credentials are not supported", false, nil)
+	}
+
+	return strings.TrimSuffix(parsedURL.String(), "/"), nil
+}
+
+// Validate returns an error describing the first invalid option, or nil
+func (opts *Options) Validate() *InspectError {
+
+	if opts.Timeout < 0 {
+		return NewInspectError(ErrorCodeInvalidRequest, "Timeout can not be negative", false, nil)
+	}
+
+	if opts.Priority < 0 {
+		return NewInspectError(ErrorCodeInvalidRequest, "Priority can not be negative", false, nil)
+	}
+
+	if opts.SampleSize < 0 {
+		return NewInspectError(ErrorCodeInvalidRequest, "Sample size can not be negative", false, nil)
+	}
+
+	if opts.MaxLinks < 0 {
+		return NewInspectError(ErrorCodeInvalidRequest, "Maximum number of links can not be negative", false, nil)
+	}
+
+	for _, linkType := range opts.LinkTypes {
+		if !containsString(AnalysableLinkTypes, linkType) {
+			return NewInspectError(ErrorCodeInvalidRequest, "Unknown link type "+linkType+". Expected one of "+strings.Join(AnalysableLinkTypes, ", "), false, nil)
+		}
+	}
+
+	if len(opts.LinkOrder) > 0 && !containsString(LinkOrders, opts.LinkOrder) {
+		return NewInspectError(ErrorCodeInvalidRequest, "Unknown link order "+opts.LinkOrder+". Expected one of "+strings.Join(LinkOrders, ", "), false, nil)
+	}
+
+	if _, knownProfile := HeaderProfiles[opts.HeaderProfile]; len(opts.HeaderProfile) > 0 && !knownProfile {
+		return NewInspectError(ErrorCodeInvalidRequest, "Unknown header profile "+opts.HeaderProfile, false, nil)
+	}
+
+	if credentialsErr := opts.validateCredentials(); credentialsErr != nil {
+		return credentialsErr
+	}
+
+	if tlsErr := opts.validateTLS(); tlsErr != nil {
+		return tlsErr
+	}
+
+	return nil
+}
+
+// Shared by inspections that don't need a transport of their own, so connections are reused between them
+var defaultTransport = (&Options{}).newTransport()
+
+// Maximum number of transports kept for options with their own networks or TLS configuration
+const maxCachedTransports = 32
+
+// Transports of options with their own networks or TLS configuration, by transportKey.
+// Inspections with the same configuration share one, instead of opening new connections each time
+var transportCache = struct {
+	lock       sync.Mutex
+	transports map[string]*http.Transport
+
+	// Keys of the transports, from the least recently used
+	keys []string
+}{transports: map[string]*http.Transport{}}
+
+// transportKey identifies the networks and TLS configuration of the options
+func (opts *Options) transportKey() string {
+	hash := sha256.New()
+
+	writeField := func(field []byte) {
+		var fieldLen [8]byte
+		binary.BigEndian.PutUint64(fieldLen[:], uint64(len(field)))
+		hash.Write(fieldLen[:])
+		hash.Write(field)
+	}
+
+	for _, allowedNetwork := range opts.AllowedNetworks {
+		writeField([]byte(allowedNetwork.String()))
+	}
+	writeField(nil)
+	writeField(opts.CABundle)
+	writeField(opts.ClientCertificate)
+	writeField(opts.ClientKey)
+	writeField([]byte(strconv.FormatBool(opts.InsecureSkipVerify)))
+
+	return string(hash.Sum(nil))
+}
+
+// sharedTransport returns the transport of the networks and TLS configuration of the options, creating it once
+func (opts *Options) sharedTransport() *http.Transport {
+	if len(opts.AllowedNetworks) == 0 && !opts.hasTLSConfig() {
+		return defaultTransport
+	}
+
+	key := opts.transportKey()
+
+	transportCache.lock.Lock()
+	defer transportCache.lock.Unlock()
+
+	for i, cachedKey := range transportCache.keys {
+		if cachedKey == key {
+			transportCache.keys = append(append(transportCache.keys[:i:i], transportCache.keys[i+1:]...), key)
+			return transportCache.transports[key]
+		}
+	}
+
+	transport := opts.newTransport()
+	transportCache.transports[key] = transport
+	transportCache.keys = append(transportCache.keys, key)
+
+	// Requests in flight on an evicted transport still complete
+	if len(transportCache.keys) > maxCachedTransports {
+		evictedKey := transportCache.keys[0]
+		transportCache.transports[evictedKey].CloseIdleConnections()
+		delete(transportCache.transports, evictedKey)
+		transportCache.keys = transportCache.keys[1:]
+	}
+
+	return transport
+}
+
+// newHTTPClient returns the client used for the web page at pageURL and its links
+func (opts *Options) newHTTPClient(pageURL *url.URL) *http.Client {
+	client := &http.Client{Transport: defaultTransport, Jar: opts.jar}
+
+	if opts.transport != nil {
+		client.Transport = opts.transport
+	} else {
+		client.Transport = opts.newPageTransport(pageURL)
+	}
+
+	if opts.DisableRedirects {
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	} else if opts.hasCredentials() {
+		client.CheckRedirect = opts.checkCredentialRedirect
+	}
+
+	return client
+}
+
+// newLinkHTTPClient returns the client used for the links of the web page at pageURL
+func (opts *Options) newLinkHTTPClient(pageURL *url.URL) *http.Client {
+	client := opts.newHTTPClient(pageURL)
+	client.Jar = opts.linkCookieJar(pageURL)
+	return client
+}
+
+func (opts *Options) shouldAnalyseLinkType(linkType string) bool {
+	return len(opts.LinkTypes) == 0 || containsString(opts.LinkTypes, linkType)
+}
+
+func (opts *Options) headers() http.Header {
+	if profile, hasProfile := HeaderProfiles[opts.HeaderProfile]; hasProfile {
+		return profile
+	}
+	return HeaderProfiles[HeaderProfileBrowser]
+}
+
+func containsString(list []string, str string) bool {
+	for _, item := range list {
+		if item == str {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package inspector
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Problem codes
+const (
+	ProblemMissingTitle       = "missing_title"
+	ProblemHeadingOrder       = "heading_order"
+	ProblemBrokenInternalLink = "broken_internal_link"
+	ProblemMixedContent       = "mixed_content"
+)
+
+// Problem is a defect of an inspected page that developers should fix
+type Problem struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Problems returns the defects found in the page: a missing title, headings out of order, scripts, form actions and other
+// active mixed content, and internal links found inaccessible so far.
+// Call it after the link analysis to include all broken links.
+func (report *InspectReport) Problems() []Problem {
+	problems := []Problem{}
+
+	if report.Error != nil {
+		return problems
+	}
+
+	if report.PageTitle == "Not defined" || len(strings.TrimSpace(report.PageTitle)) == 0 {
+		problems = append(problems, Problem{ProblemMissingTitle, "The page has no title"})
+	}
+
+	// The first heading should be h1, and headings should not skip levels, such as h2 followed by h4
+	previousLevel := 0
+	for i, heading := range report.headingOrder {
+		level := int(heading[1] - '0')
+
+		if i == 0 && level != 1 {
+			problems = append(problems, Problem{ProblemHeadingOrder, fmt.Sprintf("The first heading is %s instead of h1", heading)})
+		} else if i > 0 && level > previousLevel+1 {
+			problems = append(problems, Problem{ProblemHeadingOrder, fmt.Sprintf("h%d is followed by %s, skipping a level", previousLevel, heading)})
+		}
+
+		previousLevel = level
+	}
+
+	for _, mixedContent := range report.MixedContent {
+		if mixedContent.Kind != MixedContentActive {
+			continue
+		}
+
+		// Forms are not loaded with the page. Browsers warn before submitting them instead
+		if mixedContent.Attribute == "action" || mixedContent.Attribute == "formaction" {
+			problems = append(problems, Problem{ProblemMixedContent, fmt.Sprintf("%s %s submits to %s over plain HTTP, which browsers warn about before submitting", mixedContent.Tag, mixedContent.Attribute, mixedContent.URL)})
+		} else {
+			problems = append(problems, Problem{ProblemMixedContent, fmt.Sprintf("%s %s loads %s over plain HTTP, which browsers block", mixedContent.Tag, mixedContent.Attribute, mixedContent.URL)})
+		}
+	}
+
+	defer report.readLockLinks()()
+
+	for _, lnk := range report.Links {
+		if (lnk.Type == "absolute" || lnk.Type == "relative") && lnk.StatusCode >= 400 {
+			problems = append(problems, Problem{ProblemBrokenInternalLink, fmt.Sprintf("Link to %s returned status %d", lnk.URL, lnk.StatusCode)})
+		}
+	}
+
+	return problems
+}
@@ -0,0 +1,269 @@
+package inspector
+
+import (
+	"math"
+	"math/rand"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LinkSample describes the random sample of links checked when Options.SampleSize is set,
+// and the broken link rates estimated from it
+type LinkSample struct {
+	SampleSize int `json:"sample_size"`
+
+	// Number of analysable links in each stratum (link type and host), keyed by "type|host"
+	StrataPopulation map[string]int `json:"strata_population"`
+
+	Overall *LinkEstimate            `json:"overall"`
+	ByType  map[string]*LinkEstimate `json:"by_type"`
+}
+
+// LinkEstimate is the broken link rate of a group of links, estimated from the checked links of the sample
+type LinkEstimate struct {
+	// Number of analysable links in the group, and how many of them were checked and found broken
+	Population int `json:"population"`
+	Checked    int `json:"checked"`
+	Broken     int `json:"broken"`
+
+	// Stratified estimate of the broken link rate, with its 95% confidence interval
+	BrokenRate     float64 `json:"broken_rate"`
+	BrokenRateLow  float64 `json:"broken_rate_low"`
+	BrokenRateHigh float64 `json:"broken_rate_high"`
+
+	EstimatedBrokenLinks int `json:"estimated_broken_links"`
+}
+
+// z score of a 95% confidence interval
+const confidenceZ = 1.959964
+
+// sampleLinks picks a random sample of sampleSize links, stratified by link type and host.
+// Each stratum gets at least one link if the sample is large enough, and the rest is allocated in proportion to the stratum size.
+func (report *InspectReport) sampleLinks(links []*InspectedLink, sampleSize int) []*InspectedLink {
+	random := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	strataKeys := []string{}
+	strata := map[string][]*InspectedLink{}
+
+	for _, link := range links {
+		key := linkStratum(link)
+		if _, seen := strata[key]; !seen {
+			strataKeys = append(strataKeys, key)
+		}
+		strata[key] = append(strata[key], link)
+	}
+
+	report.LinkSample = &LinkSample{
+		SampleSize:       sampleSize,
+		StrataPopulation: map[string]int{},
+	}
+	for _, key := range strataKeys {
+		report.LinkSample.StrataPopulation[key] = len(strata[key])
+	}
+
+	allocation := allocateSample(strataKeys, strata, sampleSize, random)
+
+	sample := make([]*InspectedLink, 0, sampleSize)
+	for _, key := range strataKeys {
+		stratumLinks := strata[key]
+		random.Shuffle(len(stratumLinks), func(i, j int) {
+			stratumLinks[i], stratumLinks[j] = stratumLinks[j], stratumLinks[i]
+		})
+
+		for _, link := range stratumLinks[:allocation[key]] {
+			link.Stratum = key
+			sample = append(sample, link)
+		}
+	}
+
+	// Keep the document order, so Options.LinkOrder still applies to the sample
+	documentIndex := map[*InspectedLink]int{}
+	for i, link := range links {
+		documentIndex[link] = i
+	}
+	sort.SliceStable(sample, func(i, j int) bool {
+		return documentIndex[sample[i]] < documentIndex[sample[j]]
+	})
+
+	return sample
+}
+
+// allocateSample decides how many links to sample from each stratum
+func allocateSample(strataKeys []string, strata map[string][]*InspectedLink, sampleSize int, random *rand.Rand) map[string]int {
+	allocation := map[string]int{}
+
+	// More strata than links to sample. Sample one link from randomly chosen strata
+	if len(strataKeys) >= sampleSize {
+		shuffledKeys := append([]string{}, strataKeys...)
+		random.Shuffle(len(shuffledKeys), func(i, j int) {
+			shuffledKeys[i], shuffledKeys[j] = shuffledKeys[j], shuffledKeys[i]
+		})
+
+		for _, key := range shuffledKeys[:sampleSize] {
+			allocation[key] = 1
+		}
+		return allocation
+	}
+
+	// One link from every stratum, then the rest in proportion to the remaining links of each stratum (largest remainder)
+	remainingPopulation := 0
+	for _, key := range strataKeys {
+		allocation[key] = 1
+		remainingPopulation += len(strata[key]) - 1
+	}
+
+	remainingSample := sampleSize - len(strataKeys)
+	remainders := map[string]float64{}
+	allocated := 0
+
+	for _, key := range strataKeys {
+		share := float64(remainingSample) * float64(len(strata[key])-1) / float64(remainingPopulation)
+		allocation[key] += int(share)
+		remainders[key] = share - math.Floor(share)
+		allocated += int(share)
+	}
+
+	byRemainder := append([]string{}, strataKeys...)
+	sort.SliceStable(byRemainder, func(i, j int) bool {
+		return remainders[byRemainder[i]] > remainders[byRemainder[j]]
+	})
+
+	for i := 0; allocated < remainingSample && i < len(byRemainder); i++ {
+		if allocation[byRemainder[i]] < len(strata[byRemainder[i]]) {
+			allocation[byRemainder[i]]++
+			allocated++
+		}
+	}
+
+	return allocation
+}
+
+// estimateLinkSample updates the estimates of the sample with the links checked so far
+func (report *InspectReport) estimateLinkSample() {
+	sample := report.LinkSample
+
+	checked := map[string]int{}
+	broken := map[string]int{}
+
+	if report.previousLinks != nil {
+		for key, count := range report.previousLinks.SampleChecked {
+			checked[key] += count
+		}
+		for key, count := range report.previousLinks.SampleBroken {
+			broken[key] += count
+		}
+	}
+
+	for _, lnk := range report.Links {
+		if len(lnk.Stratum) == 0 || lnk.StatusCode == 0 {
+			continue
+		}
+
+		checked[lnk.Stratum]++
+		if lnk.StatusCode >= 400 {
+			broken[lnk.Stratum]++
+		}
+	}
+
+	sample.Overall = estimateBrokenRate(sample.StrataPopulation, checked, broken, func(key string) bool { return true })
+
+	sample.ByType = map[string]*LinkEstimate{}
+	for key := range sample.StrataPopulation {
+		linkType := stratumType(key)
+		if _, estimated := sample.ByType[linkType]; !estimated {
+			sample.ByType[linkType] = estimateBrokenRate(sample.StrataPopulation, checked, broken, func(key string) bool {
+				return stratumType(key) == linkType
+			})
+		}
+	}
+}
+
+// estimateBrokenRate combines the broken link rates of the strata, weighted by their population.
+// Strata without checked links are left out, and the weights of the others are scaled up.
+//
+// The confidence interval is a Wilson score interval, using the effective sample size of the stratified estimate,
+// which accounts for the finite population of each stratum.
+func estimateBrokenRate(population map[string]int, checked map[string]int, broken map[string]int, inGroup func(key string) bool) *LinkEstimate {
+	estimate := &LinkEstimate{}
+
+	coveredPopulation := 0
+	for key, stratumPopulation := range population {
+		if !inGroup(key) {
+			continue
+		}
+
+		estimate.Population += stratumPopulation
+		estimate.Checked += checked[key]
+		estimate.Broken += broken[key]
+
+		if checked[key] > 0 {
+			coveredPopulation += stratumPopulation
+		}
+	}
+
+	if estimate.Checked == 0 {
+		estimate.BrokenRateHigh = 1
+		return estimate
+	}
+
+	rate := 0.0
+	variance := 0.0
+
+	for key, stratumPopulation := range population {
+		if !inGroup(key) || checked[key] == 0 {
+			continue
+		}
+
+		weight := float64(stratumPopulation) / float64(coveredPopulation)
+		n := float64(checked[key])
+		stratumRate := float64(broken[key]) / n
+
+		rate += weight * stratumRate
+
+		if checked[key] > 1 {
+			finitePopulationCorrection := 1 - n/float64(stratumPopulation)
+			variance += weight * weight * finitePopulationCorrection * stratumRate * (1 - stratumRate) / (n - 1)
+		}
+	}
+
+	// Every link of the group was checked. The rate is exact
+	if estimate.Checked >= estimate.Population {
+		estimate.BrokenRate, estimate.BrokenRateLow, estimate.BrokenRateHigh = rate, rate, rate
+		estimate.EstimatedBrokenLinks = estimate.Broken
+		return estimate
+	}
+
+	effectiveSampleSize := float64(estimate.Checked) / (1 - float64(estimate.Checked)/float64(estimate.Population))
+	if variance > 0 {
+		effectiveSampleSize = rate * (1 - rate) / variance
+	}
+
+	estimate.BrokenRate = rate
+	estimate.BrokenRateLow, estimate.BrokenRateHigh = wilsonInterval(rate, effectiveSampleSize)
+	estimate.EstimatedBrokenLinks = int(math.Round(rate * float64(estimate.Population)))
+
+	return estimate
+}
+
+func wilsonInterval(rate float64, sampleSize float64) (float64, float64) {
+	zSquared := confidenceZ * confidenceZ
+	denominator := 1 + zSquared/sampleSize
+	center := (rate + zSquared/(2*sampleSize)) / denominator
+	halfWidth := confidenceZ * math.Sqrt(rate*(1-rate)/sampleSize+zSquared/(4*sampleSize*sampleSize)) / denominator
+
+	return math.Max(0, center-halfWidth), math.Min(1, center+halfWidth)
+}
+
+func linkStratum(link *InspectedLink) string {
+	host := ""
+	if parsedURL, parseErr := url.Parse(link.URL); parseErr == nil {
+		host = parsedURL.Host
+	}
+	return link.Type + "|" + host
+}
+
+func stratumType(key string) string {
+	return strings.SplitN(key, "|", 2)[0]
+}
@@ -0,0 +1,74 @@
+package inspector
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSampleLinks(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		if strings.HasPrefix(req.URL.Path, "/broken") {
+			http.NotFound(wr, req)
+		}
+	}))
+	defer server.Close()
+
+	otherHost := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+
+	var page strings.Builder
+	for i := 0; i < 60; i++ {
+		page.WriteString(fmt.Sprintf(`<a href="/ok/%d">ok</a><a href="/broken/%d">broken</a>`, i, i))
+	}
+	for i := 0; i < 30; i++ {
+		page.WriteString(fmt.Sprintf(`<a href="%s/ok/%d">external</a>`, otherHost, i))
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	opts := &Options{LinkAnalyticsDeadline: &deadline, AllowedNetworks: loopbackNetworks, SampleSize: 30}
+	report := inspectURLResponse(server.URL, &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(page.String()))}, nil, opts)
+	report.LinkAnalyticWG.Wait()
+	report.CountLinks()
+
+	if len(report.LinkSample.StrataPopulation) != 2 {
+		t.Fatalf("returned strata %v, expected 2", report.LinkSample.StrataPopulation)
+	}
+
+	overall := report.LinkSample.Overall
+	if overall.Population != 150 || overall.Checked != 30 {
+		t.Errorf("returned population %d and %d checked links, expected 150 and 30", overall.Population, overall.Checked)
+	}
+	if report.AccessibleLinkCount+report.InaccessibleLinkCount != 30 || report.InaccessibleLinkCount != overall.Broken {
+		t.Errorf("returned %d accessible and %d inaccessible links, expected 30 checked links with %d broken",
+			report.AccessibleLinkCount, report.InaccessibleLinkCount, overall.Broken)
+	}
+
+	// Proportional allocation: 120 of the 150 links are in the first stratum
+	if absolute := report.LinkSample.ByType["absolute"]; absolute.Checked != 24 {
+		t.Errorf("checked %d absolute links, expected 24", absolute.Checked)
+	}
+	if external := report.LinkSample.ByType["external"]; external.Checked != 6 || external.Broken != 0 || external.BrokenRateLow != 0 {
+		t.Errorf("external estimate is %+v, expected 6 checked links and no broken links", external)
+	}
+
+	if overall.BrokenRateLow > overall.BrokenRate || overall.BrokenRate > overall.BrokenRateHigh || overall.BrokenRateHigh-overall.BrokenRateLow < 0.05 {
+		t.Errorf("returned broken rate %f with confidence interval [%f, %f]", overall.BrokenRate, overall.BrokenRateLow, overall.BrokenRateHigh)
+	}
+}
+
+func TestEstimateBrokenRateExact(t *testing.T) {
+	population := map[string]int{"absolute|a": 10, "external|b": 30}
+	checked := map[string]int{"absolute|a": 10, "external|b": 30}
+	broken := map[string]int{"absolute|a": 2, "external|b": 6}
+
+	estimate := estimateBrokenRate(population, checked, broken, func(key string) bool { return true })
+
+	if estimate.BrokenRate != 0.2 || estimate.BrokenRateLow != 0.2 || estimate.BrokenRateHigh != 0.2 || estimate.EstimatedBrokenLinks != 8 {
+		t.Errorf("estimate with every link checked is %+v, expected an exact rate of 0.2", estimate)
+	}
+}
@@ -0,0 +1,147 @@
+package inspector
+
+import (
+	"sync"
+	"time"
+)
+
+// linkScheduler runs the link checks of all inspections, at most MaximumConcurrentLinkAnalysis at once.
+// Inspections take turns (weighted round robin), so a page with thousands of links doesn't starve a small page inspected after it.
+type linkScheduler struct {
+	lock    sync.Mutex
+	running int
+
+	// Inspections with pending link checks, in the order of their turns
+	queues []*linkQueue
+	turn   int
+}
+
+// linkQueue holds the pending link checks of one inspection
+type linkQueue struct {
+	report *InspectReport
+	links  []*InspectedLink
+
+	// Number of link checks started in each turn of this inspection
+	weight int
+
+	// Link checks left in the current turn
+	credit int
+
+	// Whether the queue is in linkScheduler.queues
+	active bool
+}
+
+var linkAnalysisScheduler = &linkScheduler{}
+
+// enqueue adds the link to the queue of its inspection. The link must already be added to report.LinkAnalyticWG
+func (scheduler *linkScheduler) enqueue(report *InspectReport, link *InspectedLink) {
+	scheduler.lock.Lock()
+	defer scheduler.lock.Unlock()
+
+	queue := report.linkQueue
+	if queue == nil {
+		queue = &linkQueue{report: report, weight: report.Options.Priority}
+		if queue.weight < 1 {
+			queue.weight = 1
+		}
+		queue.credit = queue.weight
+		report.linkQueue = queue
+
+		// Drop the pending link checks once the deadline is reached
+		requestContext := *report.RequestContext
+		go func() {
+			<-requestContext.Done()
+			scheduler.cancel(queue)
+		}()
+	}
+
+	queue.links = append(queue.links, link)
+
+	if !queue.active {
+		queue.active = true
+		scheduler.queues = append(scheduler.queues, queue)
+	}
+
+	scheduler.dispatch()
+}
+
+// dispatch starts link checks while there are free workers, taking turns between inspections.
+// The lock must be held
+func (scheduler *linkScheduler) dispatch() {
+	for scheduler.running < MaximumConcurrentLinkAnalysis && len(scheduler.queues) > 0 {
+		if scheduler.turn >= len(scheduler.queues) {
+			scheduler.turn = 0
+		}
+
+		queue := scheduler.queues[scheduler.turn]
+		link := queue.links[0]
+		queue.links = queue.links[1:]
+		queue.credit--
+
+		if len(queue.links) == 0 {
+			scheduler.deactivate(queue)
+		} else if queue.credit <= 0 {
+			// Give the next inspection its turn
+			queue.credit = queue.weight
+			scheduler.turn++
+		}
+
+		// Don't start new link checks near the deadline
+		report := queue.report
+		if deadline, hasDeadline := (*report.RequestContext).Deadline(); hasDeadline && time.Until(deadline) < LinkCheckSchedulingMargin {
+			report.markLinkUnfinished(link)
+			report.LinkAnalyticWG.Done()
+			continue
+		}
+
+		scheduler.running++
+		go scheduler.run(report, link)
+	}
+}
+
+func (scheduler *linkScheduler) run(report *InspectReport, link *InspectedLink) {
+	report.analyseLink(link.URL, link)
+
+	scheduler.lock.Lock()
+	scheduler.running--
+	scheduler.dispatch()
+	scheduler.lock.Unlock()
+
+	// Only done after dispatching, so waiting for the link analysis also waits for the scheduler to finish with the link
+	report.LinkAnalyticWG.Done()
+}
+
+// cancel drops the pending link checks of an inspection, marking them as unfinished
+func (scheduler *linkScheduler) cancel(queue *linkQueue) {
+	scheduler.lock.Lock()
+	defer scheduler.lock.Unlock()
+
+	for _, link := range queue.links {
+		queue.report.markLinkUnfinished(link)
+		queue.report.LinkAnalyticWG.Done()
+	}
+	queue.links = nil
+
+	scheduler.deactivate(queue)
+}
+
+// deactivate removes the queue from the turns. The lock must be held
+func (scheduler *linkScheduler) deactivate(queue *linkQueue) {
+	if !queue.active {
+		return
+	}
+	queue.active = false
+	queue.credit = queue.weight
+
+	for i, activeQueue := range scheduler.queues {
+		if activeQueue == queue {
+			scheduler.queues = append(scheduler.queues[:i], scheduler.queues[i+1:]...)
+
+			// The next inspection moved into the removed position, so it keeps its turn
+			if i < scheduler.turn {
+				scheduler.turn--
+			}
+			break
+		}
+	}
+}
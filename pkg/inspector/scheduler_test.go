@@ -0,0 +1,137 @@
+package inspector
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLinkSchedulerFairness(t *testing.T) {
+
+	requestedPaths := []string{}
+	requestedPathsLock := sync.Mutex{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		requestedPathsLock.Lock()
+		requestedPaths = append(requestedPaths, req.URL.Path)
+		requestedPathsLock.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	defaultMaximum := MaximumConcurrentLinkAnalysis
+	MaximumConcurrentLinkAnalysis = 1
+	defer func() { MaximumConcurrentLinkAnalysis = defaultMaximum }()
+
+	inspectPage := func(name string, linkCount int, priority int) *InspectReport {
+		var page strings.Builder
+		for i := 0; i < linkCount; i++ {
+			page.WriteString(fmt.Sprintf(`<a href="/%s/%d">link</a>`, name, i))
+		}
+
+		deadline := time.Now().Add(10 * time.Second)
+		opts := &Options{LinkAnalyticsDeadline: &deadline, AllowedNetworks: loopbackNetworks, Priority: priority}
+		return inspectURLResponse(server.URL, &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(page.String()))}, nil, opts)
+	}
+
+	bigReport := inspectPage("big", 30, 1)
+	smallReport := inspectPage("small", 3, 2)
+
+	smallReport.LinkAnalyticWG.Wait()
+	bigReport.LinkAnalyticWG.Wait()
+
+	// The small inspection gets two link checks for each one of the big inspection, instead of waiting for all of them
+	lastSmallRequest := 0
+	for i, path := range requestedPaths {
+		if strings.HasPrefix(path, "/small/") {
+			lastSmallRequest = i
+		}
+	}
+
+	if lastSmallRequest > 6 {
+		t.Errorf("last link of the small inspection was checked at position %d, expected at most 6. Order: %v", lastSmallRequest, requestedPaths)
+	}
+	if len(requestedPaths) != 33 {
+		t.Errorf("checked %d links, expected 33", len(requestedPaths))
+	}
+}
+
+func TestFinishLinkAnalysisWhileChecking(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		time.Sleep(time.Duration(len(req.URL.Path)) * time.Millisecond)
+	}))
+	defer server.Close()
+
+	var page strings.Builder
+	for i := 0; i < 20; i++ {
+		page.WriteString(fmt.Sprintf(`<a href="/%s">link</a>`, strings.Repeat("a", i)))
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	opts := &Options{LinkAnalyticsDeadline: &deadline, AllowedNetworks: loopbackNetworks}
+	report := inspectURLResponse(server.URL, &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(page.String()))}, nil, opts)
+
+	// Reports are counted and encoded while their links are checked, which must not race with the link checks
+	time.Sleep(5 * time.Millisecond)
+	report.CountLinks()
+	report.Problems()
+	if _, encodeErr := json.Marshal(report); encodeErr != nil {
+		t.Fatal(encodeErr)
+	}
+
+	report.FinishLinkAnalysis()
+	report.LinkAnalyticWG.Wait()
+	report.CountLinks()
+
+	if report.AccessibleLinkCount+report.UnfinishedLinkCount != 20 {
+		t.Errorf("returned %d accessible and %d unfinished links, expected 20 in total", report.AccessibleLinkCount, report.UnfinishedLinkCount)
+	}
+}
+
+func TestFinishLinkAnalysisLeftOutLinks(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {}))
+	defer server.Close()
+
+	page := `<a href="/a">a</a><a href="/b">b</a><a href="/c">c</a><a href="/d">d</a><a href="mailto:team@example.com">Mail</a>`
+
+	// Links the options leave out were never due to be checked, so they are not unfinished
+	for _, opts := range []Options{{MaxLinks: 1}, {SampleSize: 2}, {LinkTypes: []string{"external"}}} {
+		deadline := time.Now().Add(5 * time.Second)
+		opts.LinkAnalyticsDeadline = &deadline
+		opts.AllowedNetworks = loopbackNetworks
+
+		report := inspectURLResponse(server.URL, &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(page))}, nil, &opts)
+		report.LinkAnalyticWG.Wait()
+		report.FinishLinkAnalysis()
+		report.CountLinks()
+
+		if report.UnfinishedLinkCount != 0 {
+			t.Errorf("MaxLinks %d, SampleSize %d and LinkTypes %v returned %d unfinished links, expected 0", opts.MaxLinks, opts.SampleSize, opts.LinkTypes, report.UnfinishedLinkCount)
+		}
+	}
+}
+
+func TestCountLinksWithoutLinkChecks(t *testing.T) {
+	// Reports built by hand have no link checks to guard against
+	report := &InspectReport{Links: []*InspectedLink{{URL: "https://example.com/a", Type: "absolute", StatusCode: 404}}}
+
+	report.FinishLinkAnalysis()
+	report.CountLinks()
+	report.Problems()
+	if _, encodeErr := json.Marshal(report); encodeErr != nil {
+		t.Fatal(encodeErr)
+	}
+
+	if report.InaccessibleLinkCount != 1 {
+		t.Errorf("returned %d inaccessible links, expected 1", report.InaccessibleLinkCount)
+	}
+}
@@ -0,0 +1,304 @@
+package inspector
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Results of a security header check. See SecurityHeader.Status
+const (
+	SecurityHeaderPass = "pass"
+	SecurityHeaderWarn = "warn"
+	SecurityHeaderFail = "fail"
+)
+
+// Minimum Strict-Transport-Security max-age, 180 days, recommended for HSTS preload lists
+const MinHSTSMaxAge = 180 * 24 * 60 * 60
+
+// SecurityHeaderAudit grades the security response headers of the page
+type SecurityHeaderAudit struct {
+	// From A to F, by the Score
+	Grade string `json:"grade"`
+
+	// Sum of the weights of the passed headers, and half of the weights of the headers with warnings. Out of 100
+	Score int `json:"score"`
+
+	Headers []*SecurityHeader `json:"headers"`
+}
+
+// SecurityHeader is the result of checking one security header
+type SecurityHeader struct {
+	Name   string `json:"name"`
+	Value  string `json:"value,omitempty"`
+	Status string `json:"status"`
+
+	// Why the header did not pass, or notes on how it was checked
+	Findings []string `json:"findings"`
+}
+
+// Weights of the headers in SecurityHeaderAudit.Score
+var securityHeaderWeights = map[string]int{
+	"Strict-Transport-Security": 25,
+	"Content-Security-Policy":   25,
+	"X-Frame-Options":           15,
+	"X-Content-Type-Options":    15,
+	"Referrer-Policy":           10,
+	"Permissions-Policy":        10,
+}
+
+// Minimum scores of the grades, from the best grade
+var securityHeaderGrades = []struct {
+	grade    string
+	minScore int
+}{{"A", 90}, {"B", 75}, {"C", 60}, {"D", 45}, {"E", 30}, {"F", 0}}
+
+// Referrer policies that do not send the full URL to other websites
+var safeReferrerPolicies = []string{"no-referrer", "same-origin", "strict-origin", "strict-origin-when-cross-origin", "origin", "origin-when-cross-origin"}
+
+// parseMeta keeps the Content-Security-Policy of <meta http-equiv> tags, which browsers enforce like the header
+func (report *InspectReport) parseMeta(tkn *html.Token) {
+	if strings.EqualFold(strings.TrimSpace(tokenAttribute(tkn, "http-equiv")), "content-security-policy") {
+		report.metaCSP = append(report.metaCSP, tokenAttribute(tkn, "content"))
+	}
+}
+
+// auditSecurityHeaders checks the security headers of the page response
+func (report *InspectReport) auditSecurityHeaders(header http.Header) *SecurityHeaderAudit {
+	isHTTPS := report.pageURL() != nil && report.pageURL().Scheme == "https"
+	csp := parseCSP(header.Values("Content-Security-Policy"))
+
+	audit := &SecurityHeaderAudit{
+		Headers: []*SecurityHeader{
+			checkHSTS(header.Get("Strict-Transport-Security"), isHTTPS),
+			checkCSP(header, report.metaCSP),
+			checkFrameOptions(header.Get("X-Frame-Options"), csp),
+			checkContentTypeOptions(header.Get("X-Content-Type-Options")),
+			checkReferrerPolicy(header.Values("Referrer-Policy")),
+			checkPermissionsPolicy(header.Get("Permissions-Policy"), header.Get("Feature-Policy")),
+		},
+	}
+
+	for _, securityHeader := range audit.Headers {
+		switch securityHeader.Status {
+		case SecurityHeaderPass:
+			audit.Score += securityHeaderWeights[securityHeader.Name]
+		case SecurityHeaderWarn:
+			audit.Score += securityHeaderWeights[securityHeader.Name] / 2
+		}
+	}
+
+	for _, grade := range securityHeaderGrades {
+		if audit.Score >= grade.minScore {
+			audit.Grade = grade.grade
+			break
+		}
+	}
+
+	return audit
+}
+
+func newSecurityHeader(name string, value string) *SecurityHeader {
+	return &SecurityHeader{Name: name, Value: value, Status: SecurityHeaderPass, Findings: []string{}}
+}
+
+// finding adds a finding, and lowers the status to status if it is worse
+func (securityHeader *SecurityHeader) finding(status string, finding string) {
+	if status == SecurityHeaderFail || (status == SecurityHeaderWarn && securityHeader.Status == SecurityHeaderPass) {
+		securityHeader.Status = status
+	}
+	securityHeader.Findings = append(securityHeader.Findings, finding)
+}
+
+func checkHSTS(value string, isHTTPS bool) *SecurityHeader {
+	hsts := newSecurityHeader("Strict-Transport-Security", value)
+
+	if !isHTTPS {
+		hsts.finding(SecurityHeaderFail, "The page is not served over HTTPS")
+		return hsts
+	}
+	if len(value) == 0 {
+		hsts.finding(SecurityHeaderFail, "Missing. Browsers may connect over plain HTTP")
+		return hsts
+	}
+
+	maxAge := -1
+	includesSubDomains := false
+
+	for _, directive := range strings.Split(value, ";") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+
+		if strings.HasPrefix(directive, "max-age=") {
+			maxAge, _ = strconv.Atoi(strings.Trim(strings.TrimPrefix(directive, "max-age="), `"`))
+		} else if directive == "includesubdomains" {
+			includesSubDomains = true
+		}
+	}
+
+	if maxAge <= 0 {
+		hsts.finding(SecurityHeaderFail, "max-age is missing or zero, which disables HSTS")
+	} else if maxAge < MinHSTSMaxAge {
+		hsts.finding(SecurityHeaderWarn, "max-age is shorter than 180 days")
+	}
+	if !includesSubDomains {
+		hsts.finding(SecurityHeaderWarn, "includeSubDomains is not set")
+	}
+
+	return hsts
+}
+
+// parseCSP returns the directives of Content-Security-Policy values by their lowercase name.
+// A policy with several values is enforced by all of them, and the first one of each directive is kept
+func parseCSP(values []string) map[string][]string {
+	directives := map[string][]string{}
+
+	for _, value := range values {
+		for _, directive := range strings.Split(value, ";") {
+			parts := strings.Fields(directive)
+			if len(parts) == 0 {
+				continue
+			}
+
+			name := strings.ToLower(parts[0])
+			if _, exists := directives[name]; !exists {
+				directives[name] = parts[1:]
+			}
+		}
+	}
+
+	return directives
+}
+
+func checkCSP(header http.Header, metaCSP []string) *SecurityHeader {
+	values := append(append([]string{}, header.Values("Content-Security-Policy")...), metaCSP...)
+	csp := newSecurityHeader("Content-Security-Policy", strings.Join(values, ", "))
+
+	if len(values) == 0 {
+		if len(header.Get("Content-Security-Policy-Report-Only")) > 0 {
+			csp.finding(SecurityHeaderFail, "Only Content-Security-Policy-Report-Only is set, which is not enforced")
+		} else {
+			csp.finding(SecurityHeaderFail, "Missing. Injected scripts are not restricted")
+		}
+		return csp
+	}
+
+	if len(metaCSP) > 0 {
+		csp.finding(SecurityHeaderPass, "Set with <meta http-equiv>, which does not support frame-ancestors, report-uri and sandbox")
+	}
+
+	directives := parseCSP(values)
+
+	scriptSources, hasScriptSrc := directives["script-src"]
+	if !hasScriptSrc {
+		scriptSources, hasScriptSrc = directives["default-src"]
+	}
+	if !hasScriptSrc {
+		csp.finding(SecurityHeaderWarn, "Neither script-src nor default-src is set, so scripts are not restricted")
+		return csp
+	}
+
+	hasNonceOrHash := false
+	for _, source := range scriptSources {
+		source = strings.ToLower(source)
+		if strings.HasPrefix(source, "'nonce-") || strings.HasPrefix(source, "'sha") {
+			hasNonceOrHash = true
+		}
+	}
+
+	for _, source := range scriptSources {
+		switch strings.ToLower(source) {
+		case "'unsafe-inline'":
+			// Browsers ignore 'unsafe-inline' when a nonce or hash is set
+			if !hasNonceOrHash {
+				csp.finding(SecurityHeaderWarn, "Scripts allow 'unsafe-inline'")
+			}
+		case "'unsafe-eval'":
+			csp.finding(SecurityHeaderWarn, "Scripts allow 'unsafe-eval'")
+		case "*", "http:", "https:", "data:":
+			csp.finding(SecurityHeaderWarn, "Scripts are allowed from "+source)
+		}
+	}
+
+	return csp
+}
+
+func checkFrameOptions(value string, csp map[string][]string) *SecurityHeader {
+	frameOptions := newSecurityHeader("X-Frame-Options", value)
+
+	// frame-ancestors replaces X-Frame-Options in browsers that support it
+	if frameAncestors, hasFrameAncestors := csp["frame-ancestors"]; hasFrameAncestors {
+		for _, source := range frameAncestors {
+			if source == "*" || strings.EqualFold(source, "https:") || strings.EqualFold(source, "http:") {
+				frameOptions.finding(SecurityHeaderFail, "frame-ancestors of the Content-Security-Policy allows any website to frame the page")
+				return frameOptions
+			}
+		}
+		frameOptions.finding(SecurityHeaderPass, "Framing is restricted by frame-ancestors of the Content-Security-Policy")
+		return frameOptions
+	}
+
+	switch strings.ToUpper(strings.TrimSpace(value)) {
+	case "DENY", "SAMEORIGIN":
+	case "":
+		frameOptions.finding(SecurityHeaderFail, "Missing, and the Content-Security-Policy has no frame-ancestors. The page can be framed for clickjacking")
+	default:
+		if strings.HasPrefix(strings.ToUpper(value), "ALLOW-FROM") {
+			frameOptions.finding(SecurityHeaderWarn, "ALLOW-FROM is ignored by modern browsers. Use frame-ancestors of the Content-Security-Policy")
+		} else {
+			frameOptions.finding(SecurityHeaderFail, "Not a valid value. Use DENY or SAMEORIGIN")
+		}
+	}
+
+	return frameOptions
+}
+
+func checkContentTypeOptions(value string) *SecurityHeader {
+	contentTypeOptions := newSecurityHeader("X-Content-Type-Options", value)
+
+	if !strings.EqualFold(strings.TrimSpace(value), "nosniff") {
+		contentTypeOptions.finding(SecurityHeaderFail, "Must be nosniff to prevent MIME type sniffing")
+	}
+
+	return contentTypeOptions
+}
+
+func checkReferrerPolicy(values []string) *SecurityHeader {
+	referrerPolicy := newSecurityHeader("Referrer-Policy", strings.Join(values, ", "))
+
+	// Browsers use the last policy they support
+	policy := ""
+	for _, value := range values {
+		for _, token := range strings.Split(value, ",") {
+			if token = strings.ToLower(strings.TrimSpace(token)); len(token) > 0 {
+				policy = token
+			}
+		}
+	}
+
+	switch {
+	case len(policy) == 0:
+		referrerPolicy.finding(SecurityHeaderWarn, "Missing. Browsers default to strict-origin-when-cross-origin")
+	case policy == "unsafe-url" || policy == "no-referrer-when-downgrade":
+		referrerPolicy.finding(SecurityHeaderFail, policy+" sends the full URL to other websites")
+	case !containsString(safeReferrerPolicies, policy):
+		referrerPolicy.finding(SecurityHeaderFail, "Unknown policy "+policy)
+	}
+
+	return referrerPolicy
+}
+
+func checkPermissionsPolicy(value string, featurePolicy string) *SecurityHeader {
+	permissionsPolicy := newSecurityHeader("Permissions-Policy", value)
+
+	if len(strings.TrimSpace(value)) == 0 {
+		if len(featurePolicy) > 0 {
+			permissionsPolicy.finding(SecurityHeaderWarn, "Only the deprecated Feature-Policy is set")
+		} else {
+			permissionsPolicy.finding(SecurityHeaderWarn, "Missing. Features such as the camera and geolocation are not restricted")
+		}
+	}
+
+	return permissionsPolicy
+}
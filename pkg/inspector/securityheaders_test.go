@@ -0,0 +1,85 @@
+package inspector
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAuditSecurityHeaders(t *testing.T) {
+	secureHeader := http.Header{
+		"Strict-Transport-Security": {"max-age=63072000; includeSubDomains; preload"},
+		"Content-Security-Policy":   {"default-src 'self'; script-src 'self' 'nonce-abc' 'unsafe-inline'; frame-ancestors 'none'"},
+		"X-Content-Type-Options":    {"nosniff"},
+		"Referrer-Policy":           {"no-referrer, strict-origin-when-cross-origin"},
+		"Permissions-Policy":        {"camera=(), geolocation=()"},
+	}
+
+	tests := []struct {
+		name           string
+		url            string
+		header         http.Header
+		page           string
+		expectedGrade  string
+		expectedStatus map[string]string
+	}{
+		{"secure", "https://example.com", secureHeader, ``, "A", map[string]string{"X-Frame-Options": SecurityHeaderPass, "Content-Security-Policy": SecurityHeaderPass}},
+		{"missing", "https://example.com", http.Header{}, ``, "F", map[string]string{"Strict-Transport-Security": SecurityHeaderFail, "Referrer-Policy": SecurityHeaderWarn}},
+		{"plain http", "http://example.com", secureHeader, ``, "B", map[string]string{"Strict-Transport-Security": SecurityHeaderFail}},
+		{"meta csp", "https://example.com", http.Header{"X-Frame-Options": {"SAMEORIGIN"}},
+			`<head><meta http-equiv="Content-Security-Policy" content="script-src 'self' 'unsafe-eval'"></head>`, "E",
+			map[string]string{"Content-Security-Policy": SecurityHeaderWarn, "X-Frame-Options": SecurityHeaderPass}},
+		{"weak values", "https://example.com", http.Header{
+			"Strict-Transport-Security": {"max-age=3600"},
+			"X-Frame-Options":           {"ALLOW-FROM https://example.org"},
+			"X-Content-Type-Options":    {"sniff"},
+			"Referrer-Policy":           {"unsafe-url"},
+			"Feature-Policy":            {"camera 'none'"},
+		}, ``, "F", map[string]string{
+			"Strict-Transport-Security": SecurityHeaderWarn,
+			"X-Frame-Options":           SecurityHeaderWarn,
+			"X-Content-Type-Options":    SecurityHeaderFail,
+			"Referrer-Policy":           SecurityHeaderFail,
+			"Permissions-Policy":        SecurityHeaderWarn,
+		}},
+	}
+
+	for _, test := range tests {
+		report := inspectURLResponse(test.url, &http.Response{StatusCode: 200, Header: test.header, Body: io.NopCloser(strings.NewReader(test.page))}, nil, nil)
+		audit := report.SecurityHeaders
+
+		if audit.Grade != test.expectedGrade {
+			t.Errorf("%s: returned grade %s (score %d), expected %s", test.name, audit.Grade, audit.Score, test.expectedGrade)
+		}
+
+		for _, securityHeader := range audit.Headers {
+			if expectedStatus, checked := test.expectedStatus[securityHeader.Name]; checked && securityHeader.Status != expectedStatus {
+				t.Errorf("%s: %s is %s %v, expected %s", test.name, securityHeader.Name, securityHeader.Status, securityHeader.Findings, expectedStatus)
+			}
+		}
+	}
+}
+
+func TestAuditSecurityHeadersAfterRedirect(t *testing.T) {
+	header := http.Header{"Strict-Transport-Security": {"max-age=63072000; includeSubDomains"}}
+
+	// The scheme of the page is the one of the final URL, not of the inspected one
+	redirects := map[string]string{
+		"http://example.com":  "https://example.com/",
+		"https://example.com": "http://example.com/",
+	}
+	expectedStatus := map[string]string{
+		"http://example.com":  SecurityHeaderPass,
+		"https://example.com": SecurityHeaderFail,
+	}
+
+	for inputURL, finalURL := range redirects {
+		finalRequest, _ := http.NewRequest(http.MethodGet, finalURL, nil)
+		report := inspectURLResponse(inputURL, &http.Response{StatusCode: 200, Header: header, Body: io.NopCloser(strings.NewReader("")), Request: finalRequest}, nil, nil)
+
+		if hsts := report.SecurityHeaders.Headers[0]; hsts.Status != expectedStatus[inputURL] {
+			t.Errorf("%s redirected to %s: Strict-Transport-Security is %s %v, expected %s", inputURL, finalURL, hsts.Status, hsts.Findings, expectedStatus[inputURL])
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package inspector
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/url"
+)
+
+func (opts *Options) hasTLSConfig() bool {
+	return len(opts.CABundle) > 0 || len(opts.ClientCertificate) > 0 || len(opts.ClientKey) > 0 || opts.InsecureSkipVerify
+}
+
+func (opts *Options) validateTLS() *InspectError {
+	if len(opts.CABundle) > 0 {
+		if _, poolErr := opts.rootCAs(); poolErr != nil {
+			return poolErr
+		}
+	}
+
+	if len(opts.ClientCertificate) > 0 || len(opts.ClientKey) > 0 {
+		if _, certErr := tls.X509KeyPair(opts.ClientCertificate, opts.ClientKey); certErr != nil {
+			return NewInspectError(ErrorCodeInvalidRequest, "Client certificate and key are not a valid PEM certificate and matching private key", false, certErr)
+		}
+	}
+
+	return nil
+}
+
+// rootCAs returns the system certificate authorities and the ones of CABundle
+func (opts *Options) rootCAs() (*x509.CertPool, *InspectError) {
+	pool, poolErr := x509.SystemCertPool()
+	if poolErr != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+
+	if !pool.AppendCertsFromPEM(opts.CABundle) {
+		return nil, NewInspectError(ErrorCodeInvalidRequest, "CA bundle has no valid PEM certificates", false, nil)
+	}
+
+	return pool, nil
+}
+
+// tlsConfig returns the TLS configuration of the options, or nil for the defaults.
+// Invalid certificates are left out here, as Validate reports them
+func (opts *Options) tlsConfig() *tls.Config {
+	if !opts.hasTLSConfig() {
+		return nil
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: opts.InsecureSkipVerify}
+
+	if len(opts.CABundle) > 0 {
+		tlsConfig.RootCAs, _ = opts.rootCAs()
+	}
+
+	if len(opts.ClientCertificate) > 0 {
+		if clientCert, certErr := tls.X509KeyPair(opts.ClientCertificate, opts.ClientKey); certErr == nil {
+			tlsConfig.Certificates = []tls.Certificate{clientCert}
+		}
+	}
+
+	return tlsConfig
+}
+
+// clientCertTransport presents the client certificate only to the website of the page.
+// Redirects and link checks to other websites are sent without it
+type clientCertTransport struct {
+	pageURL *url.URL
+
+	withCertificate    http.RoundTripper
+	withoutCertificate http.RoundTripper
+}
+
+func (transport *clientCertTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if isSameOrigin(transport.pageURL, req.URL) {
+		return transport.withCertificate.RoundTrip(req)
+	}
+	return transport.withoutCertificate.RoundTrip(req)
+}
+
+// newPageTransport returns the transport of the web page at pageURL and its links, which only presents the client certificate to its website
+func (opts *Options) newPageTransport(pageURL *url.URL) http.RoundTripper {
+	pageTransport := opts.sharedTransport()
+	if len(opts.ClientCertificate) == 0 || pageURL == nil {
+		return pageTransport
+	}
+
+	optsWithoutCertificate := *opts
+	optsWithoutCertificate.ClientCertificate = nil
+	optsWithoutCertificate.ClientKey = nil
+
+	return &clientCertTransport{
+		pageURL:            pageURL,
+		withCertificate:    pageTransport,
+		withoutCertificate: optsWithoutCertificate.sharedTransport(),
+	}
+}
@@ -0,0 +1,133 @@
+package inspector
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newClientCertificate returns a self-signed client certificate and its private key in PEM format
+func newClientCertificate(t *testing.T) ([]byte, []byte) {
+	key, keyErr := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if keyErr != nil {
+		t.Fatal(keyErr)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "inspector"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	certDER, certErr := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if certErr != nil {
+		t.Fatal(certErr)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestCustomTLS(t *testing.T) {
+	clientCertPEM, clientKeyPEM := newClientCertificate(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AppendCertsFromPEM(clientCertPEM)
+
+	// Another website, which records whether the client certificate was presented to it
+	presentedToOther := false
+	other := httptest.NewUnstartedServer(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		presentedToOther = presentedToOther || len(req.TLS.PeerCertificates) > 0
+	}))
+	other.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	other.StartTLS()
+	defer other.Close()
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/moved" {
+			http.Redirect(wr, req, other.URL+"/page", http.StatusFound)
+			return
+		}
+		wr.Write([]byte(`<title>Staging</title><a href="/about">About</a><a href="` + other.URL + `/page">Other</a>`))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+
+	caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	inspect := func(path string, opts *Options) *InspectReport {
+		deadline := time.Now().Add(5 * time.Second)
+		opts.LinkAnalyticsDeadline = &deadline
+		opts.AllowedNetworks = loopbackNetworks
+
+		if validationErr := opts.Validate(); validationErr != nil {
+			t.Fatalf("options are not valid : %v", validationErr)
+		}
+
+		report := InspectURLWithOptions(server.URL+path, opts)
+		report.LinkAnalyticWG.Wait()
+		report.CountLinks()
+		return report
+	}
+
+	report := inspect("/", &Options{CABundle: caBundle, ClientCertificate: clientCertPEM, ClientKey: clientKeyPEM})
+	if report.Error != nil || report.StatusCode != http.StatusOK || report.InsecureTLS {
+		t.Fatalf("returned status %d and error %+v with the CA bundle and client certificate", report.StatusCode, report.Error)
+	}
+	if report.TLS == nil || len(report.TLS.Issues) != 0 {
+		t.Errorf("returned TLS details %+v", report.TLS)
+	}
+	if report.Links[0].StatusCode != http.StatusOK {
+		t.Errorf("internal link returned status %d with the client certificate", report.Links[0].StatusCode)
+	}
+	if presentedToOther {
+		t.Errorf("client certificate was presented to another website")
+	}
+
+	// Nor to the website the page redirects to
+	report = inspect("/moved", &Options{CABundle: caBundle, ClientCertificate: clientCertPEM, ClientKey: clientKeyPEM})
+	if report.StatusCode != http.StatusOK {
+		t.Errorf("returned status %d and error %+v after redirecting to another website", report.StatusCode, report.Error)
+	}
+	if presentedToOther {
+		t.Errorf("client certificate was presented to the website the page redirected to")
+	}
+
+	// Without the client certificate, the handshake fails
+	report = inspect("/", &Options{CABundle: caBundle})
+	if report.Error == nil {
+		t.Errorf("returned status %d without the client certificate", report.StatusCode)
+	}
+
+	report = inspect("/", &Options{InsecureSkipVerify: true, ClientCertificate: clientCertPEM, ClientKey: clientKeyPEM})
+	if report.Error != nil || !report.InsecureTLS {
+		t.Fatalf("returned error %+v and insecure_tls %v when skipping verification", report.Error, report.InsecureTLS)
+	}
+	if len(report.TLS.Issues) != 1 || report.TLS.Issues[0].Code != TLSIssueUnverified {
+		t.Errorf("returned TLS issues %+v when skipping verification", report.TLS.Issues)
+	}
+
+	for _, invalidOpts := range []*Options{
+		{CABundle: []byte("not a certificate")},
+		{ClientCertificate: clientCertPEM},
+		{ClientCertificate: clientCertPEM, ClientKey: []byte("not a key")},
+	} {
+		if validationErr := invalidOpts.Validate(); validationErr == nil || validationErr.Code != ErrorCodeInvalidRequest {
+			t.Errorf("returned %v for invalid TLS options", validationErr)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package inspector
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Certificates expiring in fewer days are flagged. See TLSIssueExpiringSoon
+const CertificateExpiryWarningDays = 30
+
+// Issues of TLS connections. See TLSDetails.Issues
+const (
+	TLSIssueHostnameMismatch = "hostname_mismatch"
+	TLSIssueExpired          = "expired"
+	TLSIssueExpiringSoon     = "expiring_soon"
+	TLSIssueUnknownAuthority = "unknown_authority"
+	TLSIssueWeakProtocol     = "weak_protocol"
+
+	// The certificate was not verified, because of Options.InsecureSkipVerify
+	TLSIssueUnverified = "unverified"
+)
+
+// Names of TLS versions, as crypto/tls has no function for them
+var tlsVersionNames = map[uint16]string{
+	tls.VersionTLS10: "TLS 1.0",
+	tls.VersionTLS11: "TLS 1.1",
+	tls.VersionTLS12: "TLS 1.2",
+	tls.VersionTLS13: "TLS 1.3",
+}
+
+// TLSDetails describes the TLS connection of a host and its certificate chain
+type TLSDetails struct {
+	Host string `json:"host"`
+
+	// Empty if the connection failed, and the details come from the certificate that failed verification
+	Version     string `json:"version,omitempty"`
+	CipherSuite string `json:"cipher_suite,omitempty"`
+
+	// Certificates sent by the server, from the certificate of the host to the one closest to the root
+	Certificates []*CertificateDetails `json:"certificates"`
+
+	// Expiry of the certificate of the host in RFC 3339 format, and the days until then. Negative if expired
+	Expires       string `json:"expires"`
+	DaysRemaining int    `json:"days_remaining"`
+
+	// See the TLSIssue constants
+	Issues []Problem `json:"issues"`
+}
+
+// CertificateDetails is a certificate of a TLS certificate chain
+type CertificateDetails struct {
+	Subject   string `json:"subject"`
+	Issuer    string `json:"issuer"`
+	NotBefore string `json:"not_before"`
+	NotAfter  string `json:"not_after"`
+
+	// DNS names and IP addresses the certificate is valid for
+	SANs []string `json:"sans,omitempty"`
+}
+
+// newTLSDetails describes the TLS connection of a response. It returns nil for plain HTTP responses
+func newTLSDetails(httpResp *http.Response) *TLSDetails {
+	if httpResp == nil || httpResp.TLS == nil || httpResp.Request == nil || httpResp.Request.URL == nil {
+		return nil
+	}
+
+	connState := httpResp.TLS
+	details := describeCertificates(httpResp.Request.URL.Hostname(), connState.PeerCertificates)
+
+	details.Version = tlsVersionNames[connState.Version]
+	if len(details.Version) == 0 {
+		details.Version = "unknown"
+	}
+	details.CipherSuite = tls.CipherSuiteName(connState.CipherSuite)
+
+	if len(connState.VerifiedChains) == 0 {
+		details.Issues = append(details.Issues, Problem{TLSIssueUnverified, "Certificate verification was skipped. The connection may not be secure"})
+	}
+
+	if connState.Version < tls.VersionTLS12 {
+		details.Issues = append(details.Issues, Problem{TLSIssueWeakProtocol, details.Version + " is deprecated. Use TLS 1.2 or 1.3"})
+	}
+
+	return details
+}
+
+// tlsDetailsFromError describes the certificate that failed verification, or returns nil for other errors
+func tlsDetailsFromError(host string, fetchErr error) *TLSDetails {
+	var hostnameErr x509.HostnameError
+	var certificateInvalidErr x509.CertificateInvalidError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+
+	switch {
+	case errors.As(fetchErr, &hostnameErr) && hostnameErr.Certificate != nil:
+		return describeCertificates(host, []*x509.Certificate{hostnameErr.Certificate})
+
+	case errors.As(fetchErr, &certificateInvalidErr) && certificateInvalidErr.Cert != nil:
+		return describeCertificates(host, []*x509.Certificate{certificateInvalidErr.Cert})
+
+	case errors.As(fetchErr, &unknownAuthorityErr) && unknownAuthorityErr.Cert != nil:
+		details := describeCertificates(host, []*x509.Certificate{unknownAuthorityErr.Cert})
+		details.Issues = append(details.Issues, Problem{TLSIssueUnknownAuthority, "The certificate is not signed by a trusted certificate authority"})
+		return details
+	}
+
+	return nil
+}
+
+// describeCertificates describes a certificate chain, and checks the certificate of the host
+func describeCertificates(host string, chain []*x509.Certificate) *TLSDetails {
+	details := &TLSDetails{
+		Host:         host,
+		Certificates: []*CertificateDetails{},
+		Issues:       []Problem{},
+	}
+
+	for _, cert := range chain {
+		certDetails := &CertificateDetails{
+			Subject:   cert.Subject.String(),
+			Issuer:    cert.Issuer.String(),
+			NotBefore: cert.NotBefore.UTC().Format(time.RFC3339),
+			NotAfter:  cert.NotAfter.UTC().Format(time.RFC3339),
+			SANs:      append([]string{}, cert.DNSNames...),
+		}
+		for _, ip := range cert.IPAddresses {
+			certDetails.SANs = append(certDetails.SANs, ip.String())
+		}
+
+		details.Certificates = append(details.Certificates, certDetails)
+	}
+
+	if len(chain) == 0 {
+		return details
+	}
+
+	leaf := chain[0]
+	details.Expires = leaf.NotAfter.UTC().Format(time.RFC3339)
+	details.DaysRemaining = int(math.Floor(time.Until(leaf.NotAfter).Hours() / 24))
+
+	if hostnameErr := leaf.VerifyHostname(host); hostnameErr != nil {
+		details.Issues = append(details.Issues, Problem{TLSIssueHostnameMismatch, "The certificate is not valid for " + host + ". It is valid for " + strings.Join(details.Certificates[0].SANs, ", ")})
+	}
+
+	if details.DaysRemaining < 0 {
+		details.Issues = append(details.Issues, Problem{TLSIssueExpired, "The certificate expired on " + details.Expires})
+	} else if details.DaysRemaining < CertificateExpiryWarningDays {
+		details.Issues = append(details.Issues, Problem{TLSIssueExpiringSoon, "The certificate expires on " + details.Expires})
+	}
+
+	return details
+}
+
+// recordLinkTLS keeps the TLS details of the first link check of each host, or of its certificate error.
+// See Options.CheckLinkTLS
+func (report *InspectReport) recordLinkTLS(linkURL *url.URL, httpResp *http.Response, httpErr error) {
+	details := newTLSDetails(httpResp)
+	if details == nil && httpErr != nil {
+		details = tlsDetailsFromError(linkURL.Hostname(), httpErr)
+	}
+	if details == nil {
+		return
+	}
+
+	defer report.lockLinks()()
+
+	if report.LinkTLS == nil {
+		report.LinkTLS = map[string]*TLSDetails{}
+	}
+	if _, recorded := report.LinkTLS[details.Host]; !recorded {
+		report.LinkTLS[details.Host] = details
+	}
+}
@@ -0,0 +1,131 @@
+package inspector
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTLSDetails(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		wr.Write([]byte(`<title>Secure</title>`))
+	}))
+	defer server.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	report := InspectURLWithOptions(server.URL, &Options{LinkAnalyticsDeadline: &deadline, AllowedNetworks: loopbackNetworks, transport: server.Client().Transport})
+	if report.TLS == nil {
+		t.Fatalf("returned no TLS details")
+	}
+	if len(report.TLS.Version) == 0 || len(report.TLS.CipherSuite) == 0 || len(report.TLS.Certificates) == 0 || len(report.TLS.Issues) != 0 {
+		t.Errorf("returned TLS details %+v", report.TLS)
+	}
+	if report.TLS.DaysRemaining < CertificateExpiryWarningDays {
+		t.Errorf("returned %d days remaining", report.TLS.DaysRemaining)
+	}
+
+	// The test certificate is not trusted by default
+	report = InspectURLWithOptions(server.URL, &Options{LinkAnalyticsDeadline: &deadline, AllowedNetworks: loopbackNetworks})
+	if report.Error == nil || report.Error.Code != ErrorCodeTLSFailure {
+		t.Fatalf("returned error %+v, expected %s", report.Error, ErrorCodeTLSFailure)
+	}
+	if report.TLS == nil || len(report.TLS.Issues) != 1 || report.TLS.Issues[0].Code != TLSIssueUnknownAuthority {
+		t.Errorf("returned TLS details %+v of the failed certificate", report.TLS)
+	}
+}
+
+func TestDescribeCertificates(t *testing.T) {
+	key, keyErr := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if keyErr != nil {
+		t.Fatal(keyErr)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		DNSNames:     []string{"example.com", "www.example.com"},
+		NotBefore:    time.Now().Add(-24 * time.Hour),
+		NotAfter:     time.Now().Add(10 * 24 * time.Hour),
+	}
+
+	certDER, certErr := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if certErr != nil {
+		t.Fatal(certErr)
+	}
+	cert, _ := x509.ParseCertificate(certDER)
+
+	details := describeCertificates("shop.example.org", []*x509.Certificate{cert})
+
+	if details.DaysRemaining != 9 {
+		t.Errorf("returned %d days remaining, expected 9", details.DaysRemaining)
+	}
+	if len(details.Issues) != 2 || details.Issues[0].Code != TLSIssueHostnameMismatch || details.Issues[1].Code != TLSIssueExpiringSoon {
+		t.Errorf("returned issues %+v", details.Issues)
+	}
+	if certDetails := details.Certificates[0]; certDetails.Subject != "CN=example.com" || len(certDetails.SANs) != 2 {
+		t.Errorf("returned certificate %+v", certDetails)
+	}
+}
+
+func TestLinkTLSWhileChecking(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		time.Sleep(time.Duration(len(req.Host)%5) * time.Millisecond)
+	}))
+	defer server.Close()
+
+	// Every link host is served by the test server
+	transport := server.Client().Transport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	transport.DialContext = func(ctx context.Context, network string, addr string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+	}
+
+	var page strings.Builder
+	for i := 0; i < 50; i++ {
+		page.WriteString(fmt.Sprintf(`<a href="https://host%d.example.com/">link</a>`, i))
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	opts := &Options{LinkAnalyticsDeadline: &deadline, CheckLinkTLS: true, transport: transport}
+	report := inspectURLResponse("https://example.com", &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(page.String()))}, nil, opts)
+
+	// Reports are encoded while the TLS details of their links are recorded, which must not race
+	checked := make(chan struct{})
+	go func() {
+		report.LinkAnalyticWG.Wait()
+		close(checked)
+	}()
+
+	for encoding := true; encoding; {
+		select {
+		case <-checked:
+			encoding = false
+		default:
+		}
+
+		if _, encodeErr := json.Marshal(report); encodeErr != nil {
+			t.Fatal(encodeErr)
+		}
+	}
+
+	report.FinishLinkAnalysis()
+
+	if len(report.LinkTLS) != 50 {
+		t.Errorf("returned TLS details of %d link hosts, expected 50", len(report.LinkTLS))
+	}
+}
@@ -0,0 +1,185 @@
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HasinduLanka/InspectGo/pkg/inspector"
+)
+
+// Limiter is a token bucket rate limiter with a cap on concurrent requests, keyed by client.
+// Each client gets Burst tokens, refilled at Rate tokens per second. Each request takes a token.
+// Zero Rate disables the token bucket, leaving only the concurrency limit.
+type Limiter struct {
+	Rate          float64
+	Burst         int
+	MaxConcurrent int
+
+	// Use the X-Forwarded-For header for the client IP.
+	// Only enable this behind a proxy that sets the header, otherwise clients can choose their own key
+	TrustForwardedFor bool
+
+	// Number of proxies in front of the server that append to X-Forwarded-For.
+	// The client IP is the address this many entries from the right, as the entries left of it are sent by the client.
+	// Defaults to 1, the address added by the nearest proxy
+	TrustedProxies int
+
+	// Identifies the client of a request, such as by a verified API key. Defaults to the client IP.
+	// Never key by anything the client can change freely, such as an unverified header
+	KeyFunc func(req *http.Request) string
+
+	clients   map[string]*clientState
+	lastPrune time.Time
+	lock      sync.Mutex
+}
+
+type clientState struct {
+	tokens     float64
+	lastRefill time.Time
+	active     int
+}
+
+// New creates a limiter allowing `rate` requests per second with bursts of `burst` requests,
+// and at most `maxConcurrent` requests at once per client. Zero maxConcurrent means no concurrency limit
+func New(rate float64, burst int, maxConcurrent int) *Limiter {
+	return &Limiter{
+		Rate:          rate,
+		Burst:         burst,
+		MaxConcurrent: maxConcurrent,
+		clients:       map[string]*clientState{},
+	}
+}
+
+// Acquire takes a token for the client. If the client is allowed, call release when its request is done.
+// Otherwise retryAfter is the time to wait before trying again.
+func (limiter *Limiter) Acquire(clientKey string) (release func(), retryAfter time.Duration, allowed bool) {
+	limiter.lock.Lock()
+	defer limiter.lock.Unlock()
+
+	now := time.Now()
+	limiter.prune(now)
+
+	client, hasClient := limiter.clients[clientKey]
+	if !hasClient {
+		client = &clientState{tokens: float64(limiter.Burst), lastRefill: now}
+		limiter.clients[clientKey] = client
+	}
+
+	// Refill the bucket for the time passed since the last request
+	client.tokens = math.Min(float64(limiter.Burst), client.tokens+now.Sub(client.lastRefill).Seconds()*limiter.Rate)
+	client.lastRefill = now
+
+	if limiter.MaxConcurrent > 0 && client.active >= limiter.MaxConcurrent {
+		// There is no way to know when a running request finishes, so ask the client to retry soon
+		return nil, time.Second, false
+	}
+
+	if limiter.Rate > 0 && client.tokens < 1 {
+		return nil, time.Duration((1 - client.tokens) / limiter.Rate * float64(time.Second)), false
+	}
+
+	if limiter.Rate > 0 {
+		client.tokens--
+	}
+	client.active++
+
+	releaseOnce := sync.Once{}
+	release = func() {
+		releaseOnce.Do(func() {
+			limiter.lock.Lock()
+			client.active--
+			limiter.lock.Unlock()
+		})
+	}
+
+	return release, 0, true
+}
+
+// Admit checks the rate limit for the request. If the request is not allowed,
+// it responds with status 429 and a Retry-After header, and returns false.
+// Otherwise call release when the request is done.
+func (limiter *Limiter) Admit(wr http.ResponseWriter, req *http.Request) (release func(), admitted bool) {
+	clientKey := limiter.ClientIP(req)
+	if limiter.KeyFunc != nil {
+		clientKey = limiter.KeyFunc(req)
+	}
+
+	release, retryAfter, allowed := limiter.Acquire(clientKey)
+	if allowed {
+		return release, true
+	}
+
+	retryAfterSeconds := int(math.Ceil(retryAfter.Seconds()))
+
+	limitErr := inspector.NewInspectError(inspector.ErrorCodeRateLimited, "Too many requests. Retry after "+strconv.Itoa(retryAfterSeconds)+" seconds", true, nil)
+	limitErr.Details = map[string]string{"retry_after": strconv.Itoa(retryAfterSeconds)}
+
+	wr.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds))
+	inspector.WriteError(wr, http.StatusTooManyRequests, limitErr)
+
+	return nil, false
+}
+
+// Middleware applies the rate limit to every request of the handler
+func (limiter *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		release, admitted := limiter.Admit(wr, req)
+		if !admitted {
+			return
+		}
+		defer release()
+
+		next.ServeHTTP(wr, req)
+	})
+}
+
+// ClientIP identifies the client of a request by its IP address
+func (limiter *Limiter) ClientIP(req *http.Request) string {
+	if limiter.TrustForwardedFor {
+		if forwardedFor := req.Header.Values("X-Forwarded-For"); len(forwardedFor) > 0 {
+			addresses := strings.Split(strings.Join(forwardedFor, ","), ",")
+
+			trustedProxies := limiter.TrustedProxies
+			if trustedProxies < 1 {
+				trustedProxies = 1
+			}
+
+			// A shorter header did not pass through every proxy. Its leftmost address is the closest to the client
+			clientIndex := len(addresses) - trustedProxies
+			if clientIndex < 0 {
+				clientIndex = 0
+			}
+
+			if clientIP := strings.TrimSpace(addresses[clientIndex]); len(clientIP) > 0 {
+				return "ip:" + clientIP
+			}
+		}
+	}
+
+	host, _, splitErr := net.SplitHostPort(req.RemoteAddr)
+	if splitErr != nil {
+		host = req.RemoteAddr
+	}
+
+	return "ip:" + host
+}
+
+// prune forgets idle clients whose bucket is full again, so the limiter doesn't grow forever
+func (limiter *Limiter) prune(now time.Time) {
+	if now.Sub(limiter.lastPrune) < time.Minute {
+		return
+	}
+	limiter.lastPrune = now
+
+	for clientKey, client := range limiter.clients {
+		refilledTokens := client.tokens + now.Sub(client.lastRefill).Seconds()*limiter.Rate
+		if client.active == 0 && (limiter.Rate <= 0 || refilledTokens >= float64(limiter.Burst)) {
+			delete(limiter.clients, clientKey)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLimiterTokenBucket(t *testing.T) {
+	limiter := New(1, 2, 0)
+
+	for i := 0; i < 2; i++ {
+		release, _, allowed := limiter.Acquire("client")
+		if !allowed {
+			t.Fatalf("request %d within the burst was not allowed", i)
+		}
+		release()
+	}
+
+	_, retryAfter, allowed := limiter.Acquire("client")
+	if allowed {
+		t.Errorf("request beyond the burst was allowed")
+	}
+	if retryAfter <= 0 || retryAfter > time.Second {
+		t.Errorf("returned retry after %s, expected up to a second", retryAfter)
+	}
+
+	if _, _, allowed := limiter.Acquire("another client"); !allowed {
+		t.Errorf("another client was limited by the first client")
+	}
+}
+
+func TestLimiterConcurrency(t *testing.T) {
+	limiter := New(0, 0, 1)
+
+	release, _, allowed := limiter.Acquire("client")
+	if !allowed {
+		t.Fatalf("first request was not allowed")
+	}
+
+	if _, _, allowed := limiter.Acquire("client"); allowed {
+		t.Errorf("concurrent request beyond the limit was allowed")
+	}
+
+	release()
+	release()
+
+	if _, _, allowed := limiter.Acquire("client"); !allowed {
+		t.Errorf("request after the release was not allowed")
+	}
+}
+
+func TestLimiterMiddleware(t *testing.T) {
+	limiter := New(0.5, 1, 0)
+	handler := limiter.Middleware(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {}))
+
+	statusCodes := []int{}
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/api/inspect", nil)
+		req.Header.Set("X-Forwarded-For", "203.0.113.1")
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		statusCodes = append(statusCodes, recorder.Code)
+
+		if recorder.Code == http.StatusTooManyRequests && recorder.Header().Get("Retry-After") != "2" {
+			t.Errorf("returned Retry-After %q, expected 2", recorder.Header().Get("Retry-After"))
+		}
+	}
+
+	if statusCodes[0] != http.StatusOK || statusCodes[1] != http.StatusTooManyRequests {
+		t.Errorf("returned status codes %v, expected 200 and 429", statusCodes)
+	}
+
+	// Without TrustForwardedFor, the header can not be used to change the client key
+	req := httptest.NewRequest(http.MethodPost, "/api/inspect", nil)
+	req.Header.Set("X-Forwarded-For", "203.0.113.2")
+	if clientKey := limiter.ClientIP(req); clientKey != "ip:192.0.2.1" {
+		t.Errorf("returned client key %s, expected ip:192.0.2.1", clientKey)
+	}
+}
+
+func TestLimiterClientIPForwardedFor(t *testing.T) {
+	limiter := New(0.5, 1, 0)
+	limiter.TrustForwardedFor = true
+
+	// The client controls the leftmost addresses, so changing them must not give it a fresh bucket
+	for _, spoofedIP := range []string{"198.51.100.1", "198.51.100.2"} {
+		req := httptest.NewRequest(http.MethodPost, "/api/inspect", nil)
+		req.Header.Set("X-Forwarded-For", spoofedIP+", 203.0.113.1")
+
+		if clientKey := limiter.ClientIP(req); clientKey != "ip:203.0.113.1" {
+			t.Errorf("returned client key %s, expected ip:203.0.113.1", clientKey)
+		}
+	}
+
+	handler := limiter.Middleware(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {}))
+	statusCodes := []int{}
+	for _, spoofedIP := range []string{"198.51.100.3", "198.51.100.4"} {
+		req := httptest.NewRequest(http.MethodPost, "/api/inspect", nil)
+		req.Header.Set("X-Forwarded-For", spoofedIP+", 203.0.113.2")
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		statusCodes = append(statusCodes, recorder.Code)
+	}
+	if statusCodes[0] != http.StatusOK || statusCodes[1] != http.StatusTooManyRequests {
+		t.Errorf("returned status codes %v, expected 200 and 429", statusCodes)
+	}
+
+	// Behind two proxies, the client IP is the second address from the right, also across several headers
+	limiter.TrustedProxies = 2
+	req := httptest.NewRequest(http.MethodPost, "/api/inspect", nil)
+	req.Header.Add("X-Forwarded-For", "198.51.100.5, 203.0.113.3")
+	req.Header.Add("X-Forwarded-For", "192.0.2.10")
+	if clientKey := limiter.ClientIP(req); clientKey != "ip:203.0.113.3" {
+		t.Errorf("returned client key %s, expected ip:203.0.113.3", clientKey)
+	}
+}